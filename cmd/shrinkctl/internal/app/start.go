@@ -6,8 +6,8 @@ import (
 	"os"
 	"syscall"
 
-	"git.sr.ht/~jamesponddotco/imgdiet-go"
 	"git.sr.ht/~jamesponddotco/shrinkimages/internal/config"
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
 	"git.sr.ht/~jamesponddotco/shrinkimages/internal/server"
 	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
 	"go.uber.org/zap"
@@ -32,8 +32,8 @@ func StartAction(configPath string) error {
 		return fmt.Errorf("%w", err)
 	}
 
-	imgdiet.Start(nil)
-	defer imgdiet.Stop()
+	imageutil.Startup(logger)
+	defer imageutil.Shutdown()
 
 	srv, err := server.New(cfg, logger)
 	if err != nil {
@@ -4,16 +4,15 @@ go 1.21
 
 require (
 	git.sr.ht/~jamesponddotco/httpx-go v0.0.0-20230516151239-08a439b40481
-	git.sr.ht/~jamesponddotco/imgdiet-go v0.1.1
 	git.sr.ht/~jamesponddotco/pagecache-go v0.0.0-20230501194707-0f89dd22f418
 	git.sr.ht/~jamesponddotco/xstd-go v0.0.0-20230709233114-b986b57bdabd
+	github.com/davidbyttow/govips/v2 v2.13.0
 	go.uber.org/zap v1.24.0
 	golang.org/x/time v0.3.0
 )
 
 require (
 	git.sr.ht/~jamesponddotco/recache-go v1.0.1 // indirect
-	github.com/davidbyttow/govips/v2 v2.13.0 // indirect
 	go.uber.org/atomic v1.7.0 // indirect
 	go.uber.org/multierr v1.6.0 // indirect
 	golang.org/x/crypto v0.8.0 // indirect
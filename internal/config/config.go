@@ -131,6 +131,9 @@ type Service struct {
 
 	// MaxAllowedHeight is the maximum allowed height of the image.
 	MaxAllowedHeight uint `json:"maxAllowedHeight"`
+
+	// Watermark is the path to the watermark image applied when requested.
+	Watermark string `json:"watermark"`
 }
 
 // Config represents the application configuration.
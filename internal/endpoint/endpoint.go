@@ -13,4 +13,10 @@ const (
 
 	// Shrink is the endpoint for the shrink handler.
 	Shrink string = Root + CurrentAPIVersion + "/shrink"
+
+	// Placeholder is the endpoint for the placeholder handler.
+	Placeholder string = Root + CurrentAPIVersion + "/placeholder"
+
+	// Info is the endpoint for the info handler.
+	Info string = Root + CurrentAPIVersion + "/info"
 )
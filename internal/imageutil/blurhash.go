@@ -0,0 +1,193 @@
+package imageutil
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// base83 is the alphabet BlurHash strings are encoded with.
+const base83 string = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// Minimum and maximum number of components along each axis of a BlurHash.
+const (
+	minComponents int = 1
+	maxComponents int = 9
+)
+
+// BlurHash encodes an image as a BlurHash string, a compact representation
+// of its colors that clients decode into a blurred placeholder. See
+// https://blurha.sh.
+//
+// Pixels are 8-bit sRGB values in RGB order, row by row. The number of
+// components sets the level of detail along each axis and must be between 1
+// and 9.
+func BlurHash(pixels []byte, width, height, xComponents, yComponents int) (string, error) {
+	if xComponents < minComponents || xComponents > maxComponents || yComponents < minComponents || yComponents > maxComponents {
+		return "", fmt.Errorf("%w: %dx%d components", ErrEncodeBlurHash, xComponents, yComponents)
+	}
+
+	if width < 1 || height < 1 || len(pixels) != width*height*3 {
+		return "", fmt.Errorf("%w: %d bytes for %dx%d pixels", ErrEncodeBlurHash, len(pixels), width, height)
+	}
+
+	var linear [256]float64
+
+	for value := 0; value < len(linear); value++ {
+		linear[value] = srgbToLinear(uint8(value))
+	}
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			var factor [3]float64
+
+			for y := 0; y < height; y++ {
+				basisY := math.Cos(math.Pi * float64(j) * float64(y) / float64(height))
+
+				for x := 0; x < width; x++ {
+					var (
+						basis  = math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) * basisY
+						offset = (y*width + x) * 3
+					)
+
+					factor[0] += basis * linear[pixels[offset]]
+					factor[1] += basis * linear[pixels[offset+1]]
+					factor[2] += basis * linear[pixels[offset+2]]
+				}
+			}
+
+			normalization := 2.0
+			if i == 0 && j == 0 {
+				normalization = 1
+			}
+
+			scale := normalization / float64(width*height)
+
+			factors = append(factors, [3]float64{factor[0] * scale, factor[1] * scale, factor[2] * scale})
+		}
+	}
+
+	var (
+		hash     strings.Builder
+		dc       = factors[0]
+		ac       = factors[1:]
+		maxValue = 1.0
+	)
+
+	encode83(&hash, (xComponents-1)+(yComponents-1)*9, 1)
+
+	if len(ac) > 0 {
+		var actualMax float64
+
+		for _, factor := range ac {
+			actualMax = max(actualMax, math.Abs(factor[0]), math.Abs(factor[1]), math.Abs(factor[2]))
+		}
+
+		quantizedMax := int(max(0, min(82, math.Floor(actualMax*166-0.5))))
+		maxValue = float64(quantizedMax+1) / 166
+
+		encode83(&hash, quantizedMax, 1)
+	} else {
+		encode83(&hash, 0, 1)
+	}
+
+	encode83(&hash, int(linearToSRGB(dc[0]))<<16|int(linearToSRGB(dc[1]))<<8|int(linearToSRGB(dc[2])), 4)
+
+	for _, factor := range ac {
+		encode83(&hash, quantizeAC(factor[0], maxValue)*19*19+quantizeAC(factor[1], maxValue)*19+quantizeAC(factor[2], maxValue), 2)
+	}
+
+	return hash.String(), nil
+}
+
+// quantizeAC quantizes an AC component of a BlurHash to a value between 0
+// and 18.
+func quantizeAC(value, maxValue float64) int {
+	value /= maxValue
+
+	return int(max(0, min(18, math.Floor(math.Copysign(math.Sqrt(math.Abs(value)), value)*9+9.5))))
+}
+
+// encode83 writes value to hash as length base 83 digits.
+func encode83(hash *strings.Builder, value, length int) {
+	divisor := 1
+
+	for i := 1; i < length; i++ {
+		divisor *= 83
+	}
+
+	for ; divisor > 0; divisor /= 83 {
+		hash.WriteByte(base83[(value/divisor)%83])
+	}
+}
+
+// srgbToLinear converts an 8-bit sRGB value to linear light, between 0 and 1.
+func srgbToLinear(value uint8) float64 {
+	v := float64(value) / 255
+
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB converts a linear light value to an 8-bit sRGB value.
+func linearToSRGB(value float64) uint8 {
+	v := min(max(value, 0), 1)
+
+	if v <= 0.0031308 {
+		return uint8(v*12.92*255 + 0.5)
+	}
+
+	return uint8((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+// DominantColor returns the most common color among pixels, given as 8-bit
+// sRGB values in RGB order. Similar colors are grouped together, and the
+// average of the largest group is returned.
+func DominantColor(pixels []byte) Color {
+	type bucket struct {
+		r, g, b, count int
+	}
+
+	var (
+		buckets = make(map[int]*bucket)
+		largest = &bucket{}
+	)
+
+	for offset := 0; offset+2 < len(pixels); offset += 3 {
+		var (
+			r, g, b = int(pixels[offset]), int(pixels[offset+1]), int(pixels[offset+2])
+			key     = r>>4<<8 | g>>4<<4 | b>>4
+		)
+
+		current, ok := buckets[key]
+		if !ok {
+			current = &bucket{}
+			buckets[key] = current
+		}
+
+		current.r += r
+		current.g += g
+		current.b += b
+		current.count++
+
+		if current.count > largest.count {
+			largest = current
+		}
+	}
+
+	if largest.count == 0 {
+		return Color{A: 255}
+	}
+
+	return Color{
+		R: uint8((largest.r + largest.count/2) / largest.count),
+		G: uint8((largest.g + largest.count/2) / largest.count),
+		B: uint8((largest.b + largest.count/2) / largest.count),
+		A: 255,
+	}
+}
@@ -0,0 +1,124 @@
+package imageutil_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
+)
+
+// solid returns the pixels of a width by height image filled with a single
+// color.
+func solid(width, height int, r, g, b byte) []byte {
+	return bytes.Repeat([]byte{r, g, b}, width*height)
+}
+
+func TestBlurHash(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		pixels      []byte
+		width       int
+		height      int
+		xComponents int
+		yComponents int
+		want        string
+		wantErr     error
+	}{
+		{
+			name:        "black",
+			pixels:      solid(8, 6, 0, 0, 0),
+			width:       8,
+			height:      6,
+			xComponents: 4,
+			yComponents: 3,
+			want:        "L00000fQfQfQfQfQfQfQfQfQfQfQ",
+		},
+		{
+			name:        "red with a single component",
+			pixels:      solid(4, 4, 255, 0, 0),
+			width:       4,
+			height:      4,
+			xComponents: 1,
+			yComponents: 1,
+			want:        "00TI:j",
+		},
+		{
+			name:        "too many components",
+			pixels:      solid(4, 4, 0, 0, 0),
+			width:       4,
+			height:      4,
+			xComponents: 10,
+			yComponents: 3,
+			wantErr:     imageutil.ErrEncodeBlurHash,
+		},
+		{
+			name:        "pixels do not match dimensions",
+			pixels:      solid(4, 3, 0, 0, 0),
+			width:       4,
+			height:      4,
+			xComponents: 4,
+			yComponents: 3,
+			wantErr:     imageutil.ErrEncodeBlurHash,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := imageutil.BlurHash(tt.pixels, tt.width, tt.height, tt.xComponents, tt.yComponents)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("BlurHash() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("BlurHash() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDominantColor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		pixels []byte
+		want   imageutil.Color
+	}{
+		{
+			name:   "solid",
+			pixels: solid(4, 4, 0x1a, 0x2b, 0x3c),
+			want:   imageutil.Color{R: 0x1a, G: 0x2b, B: 0x3c, A: 255},
+		},
+		{
+			name: "most common color wins",
+			pixels: append(
+				solid(3, 1, 200, 10, 10),
+				append(solid(2, 1, 202, 12, 12), solid(4, 1, 0, 0, 255)...)...,
+			),
+			want: imageutil.Color{R: 201, G: 11, B: 11, A: 255},
+		},
+		{
+			name: "empty",
+			want: imageutil.Color{A: 255},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := imageutil.DominantColor(tt.pixels); got != tt.want {
+				t.Errorf("DominantColor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
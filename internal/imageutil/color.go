@@ -0,0 +1,77 @@
+package imageutil
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// Color represents an RGBA color.
+type Color struct {
+	R uint8
+	G uint8
+	B uint8
+	A uint8
+}
+
+// ParseColor parses a hexadecimal color in the #rgb, #rrggbb or #rrggbbaa
+// formats. The leading # is optional and colors without an alpha channel are
+// opaque.
+func ParseColor(value string) (Color, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "#")
+
+	if len(value) == 3 {
+		value = string([]byte{value[0], value[0], value[1], value[1], value[2], value[2]})
+	}
+
+	if len(value) == 6 {
+		value += "ff"
+	}
+
+	if len(value) != 8 {
+		return Color{}, fmt.Errorf("%w: %q", ErrInvalidColor, value)
+	}
+
+	rgba, err := hex.DecodeString(value)
+	if err != nil {
+		return Color{}, fmt.Errorf("%w: %w", ErrInvalidColor, err)
+	}
+
+	return Color{
+		R: rgba[0],
+		G: rgba[1],
+		B: rgba[2],
+		A: rgba[3],
+	}, nil
+}
+
+// vips returns the color as a libvips color.
+func (c Color) vips() *vips.ColorRGBA {
+	return &vips.ColorRGBA{
+		R: c.R,
+		G: c.G,
+		B: c.B,
+		A: c.A,
+	}
+}
+
+// vipsRGB returns the color without its alpha channel as a libvips color.
+func (c Color) vipsRGB() *vips.Color {
+	return &vips.Color{
+		R: c.R,
+		G: c.G,
+		B: c.B,
+	}
+}
+
+// Hex returns the color in the #rrggbb format, or #rrggbbaa if it's not
+// opaque.
+func (c Color) Hex() string {
+	if c.A == 255 {
+		return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+	}
+
+	return fmt.Sprintf("#%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+}
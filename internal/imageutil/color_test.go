@@ -0,0 +1,95 @@
+package imageutil_test
+
+import (
+	"errors"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
+)
+
+func TestParseColor(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		give    string
+		want    imageutil.Color
+		wantErr error
+	}{
+		{
+			name: "short form",
+			give: "#fff",
+			want: imageutil.Color{R: 255, G: 255, B: 255, A: 255},
+		},
+		{
+			name: "long form without hash",
+			give: "1a2B3c",
+			want: imageutil.Color{R: 0x1a, G: 0x2b, B: 0x3c, A: 255},
+		},
+		{
+			name: "with alpha",
+			give: "#00000080",
+			want: imageutil.Color{R: 0, G: 0, B: 0, A: 0x80},
+		},
+		{
+			name:    "invalid length",
+			give:    "#12345",
+			wantErr: imageutil.ErrInvalidColor,
+		},
+		{
+			name:    "invalid digits",
+			give:    "#gggggg",
+			wantErr: imageutil.ErrInvalidColor,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := imageutil.ParseColor(tt.give)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseColor() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseColor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColor_Hex(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give imageutil.Color
+		want string
+	}{
+		{
+			name: "opaque",
+			give: imageutil.Color{R: 0x1a, G: 0x2b, B: 0x3c, A: 255},
+			want: "#1a2b3c",
+		},
+		{
+			name: "translucent",
+			give: imageutil.Color{R: 0, G: 0, B: 0, A: 0x80},
+			want: "#00000080",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.give.Hex(); got != tt.want {
+				t.Errorf("Hex() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
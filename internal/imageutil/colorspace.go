@@ -0,0 +1,76 @@
+package imageutil
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// p3Profile is the name of the Display P3 profile built into libvips.
+const p3Profile string = "p3"
+
+// Colorspace represents the color space an image is converted to.
+type Colorspace string
+
+// List of color spaces supported by the service.
+const (
+	// ColorspaceSRGB converts every image with an ICC profile, as well as
+	// CMYK images, to sRGB with a compact embedded profile.
+	ColorspaceSRGB Colorspace = "srgb"
+
+	// ColorspaceP3 converts images with an ICC profile to Display P3 and keeps
+	// the profile, so wide-gamut colors are preserved. CMYK images are
+	// converted to sRGB, and so are images encoded as GIF, since their
+	// profile cannot be kept.
+	ColorspaceP3 Colorspace = "p3"
+
+	// ColorspaceCMYKToSRGB converts CMYK images to sRGB and leaves the others
+	// as they are.
+	ColorspaceCMYKToSRGB Colorspace = "cmyk-to-srgb"
+)
+
+// ParseColorspace parses the name of a color space, ignoring case.
+func ParseColorspace(name string) (Colorspace, error) {
+	colorspace := Colorspace(strings.ToLower(strings.TrimSpace(name)))
+
+	switch colorspace {
+	case ColorspaceSRGB, ColorspaceP3, ColorspaceCMYKToSRGB:
+		return colorspace, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedColorspace, name)
+	}
+}
+
+// wideGamut reports whether images in colorspace are kept in Display P3 when
+// encoded in format.
+func wideGamut(colorspace Colorspace, format Format) bool {
+	return colorspace == ColorspaceP3 && (format == FormatJPEG || format == FormatPNG || format == FormatWebP || format == FormatAVIF)
+}
+
+// convertColorspace converts the image to colorspace before it's encoded in
+// format.
+func (i *Image) convertColorspace(colorspace Colorspace, format Format) error {
+	cmyk := i.reference.Interpretation() == vips.InterpretationCMYK
+
+	switch {
+	case colorspace == ColorspaceCMYKToSRGB && !cmyk:
+		return nil
+	case wideGamut(colorspace, format) && !cmyk:
+		if !i.reference.HasICCProfile() {
+			return nil
+		}
+
+		if err := i.reference.TransformICCProfile(p3Profile); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		return nil
+	}
+
+	if err := i.reference.OptimizeICCProfile(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package imageutil_test
+
+import (
+	"errors"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
+)
+
+func TestParseColorspace(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		give    string
+		want    imageutil.Colorspace
+		wantErr error
+	}{
+		{
+			name: "sRGB",
+			give: "sRGB",
+			want: imageutil.ColorspaceSRGB,
+		},
+		{
+			name: "Display P3",
+			give: " p3 ",
+			want: imageutil.ColorspaceP3,
+		},
+		{
+			name: "CMYK to sRGB",
+			give: "cmyk-to-srgb",
+			want: imageutil.ColorspaceCMYKToSRGB,
+		},
+		{
+			name:    "unsupported color space",
+			give:    "adobe-rgb",
+			wantErr: imageutil.ErrUnsupportedColorspace,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := imageutil.ParseColorspace(tt.give)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseColorspace() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseColorspace() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
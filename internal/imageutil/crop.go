@@ -0,0 +1,132 @@
+package imageutil
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Length represents a length in pixels or as a percentage of an image
+// dimension.
+type Length struct {
+	// Value is the length in pixels, or a percentage from 0 to 100 if Percent
+	// is true.
+	Value float64
+
+	// Percent reports whether Value is a percentage.
+	Percent bool
+}
+
+// resolve returns the length in pixels relative to the given dimension.
+func (l Length) resolve(dimension int) int {
+	if l.Percent {
+		return int(math.Round(l.Value * float64(dimension) / 100))
+	}
+
+	return int(math.Round(l.Value))
+}
+
+// Region represents a rectangular region of an image.
+type Region struct {
+	Left   Length
+	Top    Length
+	Width  Length
+	Height Length
+}
+
+// Point represents a point in an image.
+type Point struct {
+	X Length
+	Y Length
+}
+
+// point represents a point in an image in pixels.
+type point struct {
+	x int
+	y int
+}
+
+// ParseRegion parses a region in the "x,y,width,height" format, where every
+// value is a number of pixels or a percentage such as "25%".
+func ParseRegion(value string) (Region, error) {
+	lengths, err := parseLengths(value, 4)
+	if err != nil {
+		return Region{}, fmt.Errorf("%w: %w", ErrInvalidRegion, err)
+	}
+
+	return Region{
+		Left:   lengths[0],
+		Top:    lengths[1],
+		Width:  lengths[2],
+		Height: lengths[3],
+	}, nil
+}
+
+// ParsePoint parses a point in the "x,y" format, where every value is a number
+// of pixels or a percentage such as "25%".
+func ParsePoint(value string) (Point, error) {
+	lengths, err := parseLengths(value, 2)
+	if err != nil {
+		return Point{}, fmt.Errorf("%w: %w", ErrInvalidPoint, err)
+	}
+
+	return Point{
+		X: lengths[0],
+		Y: lengths[1],
+	}, nil
+}
+
+// parseLengths parses a comma-separated list of exactly n non-negative
+// lengths.
+func parseLengths(value string, n int) ([]Length, error) {
+	fields := strings.Split(value, ",")
+	if len(fields) != n {
+		return nil, fmt.Errorf("expected %d values, got %d", n, len(fields))
+	}
+
+	lengths := make([]Length, 0, n)
+
+	for _, field := range fields {
+		var (
+			field   = strings.TrimSpace(field)
+			percent = strings.HasSuffix(field, "%")
+		)
+
+		number, err := strconv.ParseFloat(strings.TrimSuffix(field, "%"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		if number < 0 || math.IsNaN(number) || math.IsInf(number, 0) || (percent && number > 100) {
+			return nil, fmt.Errorf("value out of range: %s", field)
+		}
+
+		lengths = append(lengths, Length{Value: number, Percent: percent})
+	}
+
+	return lengths, nil
+}
+
+// crop extracts a region of the image, clipped to the image bounds. It
+// returns the position of the region's top-left corner.
+func (i *Image) crop(region Region) (point, error) {
+	var (
+		imageWidth  = i.reference.Width()
+		imageHeight = i.reference.PageHeight()
+		left        = min(region.Left.resolve(imageWidth), imageWidth)
+		top         = min(region.Top.resolve(imageHeight), imageHeight)
+		width       = min(region.Width.resolve(imageWidth), imageWidth-left)
+		height      = min(region.Height.resolve(imageHeight), imageHeight-top)
+	)
+
+	if width <= 0 || height <= 0 {
+		return point{}, fmt.Errorf("%w: region is outside the image", ErrInvalidRegion)
+	}
+
+	if err := i.reference.ExtractArea(left, top, width, height); err != nil {
+		return point{}, fmt.Errorf("%w", err)
+	}
+
+	return point{x: left, y: top}, nil
+}
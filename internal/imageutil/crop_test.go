@@ -0,0 +1,114 @@
+package imageutil_test
+
+import (
+	"errors"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
+)
+
+func TestParseRegion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		give    string
+		want    imageutil.Region
+		wantErr error
+	}{
+		{
+			name: "pixels",
+			give: "10,20,300,200",
+			want: imageutil.Region{
+				Left:   imageutil.Length{Value: 10},
+				Top:    imageutil.Length{Value: 20},
+				Width:  imageutil.Length{Value: 300},
+				Height: imageutil.Length{Value: 200},
+			},
+		},
+		{
+			name: "percentages and pixels",
+			give: "10%, 0, 50%, 120",
+			want: imageutil.Region{
+				Left:   imageutil.Length{Value: 10, Percent: true},
+				Top:    imageutil.Length{Value: 0},
+				Width:  imageutil.Length{Value: 50, Percent: true},
+				Height: imageutil.Length{Value: 120},
+			},
+		},
+		{
+			name:    "missing values",
+			give:    "10,20,300",
+			wantErr: imageutil.ErrInvalidRegion,
+		},
+		{
+			name:    "negative value",
+			give:    "-10,20,300,200",
+			wantErr: imageutil.ErrInvalidRegion,
+		},
+		{
+			name:    "percentage above 100",
+			give:    "0,0,150%,100%",
+			wantErr: imageutil.ErrInvalidRegion,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := imageutil.ParseRegion(tt.give)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseRegion() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseRegion() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePoint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		give    string
+		want    imageutil.Point
+		wantErr error
+	}{
+		{
+			name: "percentages",
+			give: "33.3%,50%",
+			want: imageutil.Point{
+				X: imageutil.Length{Value: 33.3, Percent: true},
+				Y: imageutil.Length{Value: 50, Percent: true},
+			},
+		},
+		{
+			name:    "not a number",
+			give:    "left,top",
+			wantErr: imageutil.ErrInvalidPoint,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := imageutil.ParsePoint(tt.give)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParsePoint() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("ParsePoint() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
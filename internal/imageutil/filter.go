@@ -0,0 +1,196 @@
+package imageutil
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// Parameters of the libvips sharpen operation, set to the libvips defaults.
+// Edges are sharpened when the gradient is above sharpenThreshold, by up to
+// sharpenAmount.
+const (
+	sharpenThreshold float64 = 2
+	sharpenAmount    float64 = 3
+)
+
+// filter applies the adjustments and filters in opts to the image, in the
+// following order: grayscale or tint, brightness, contrast and gamma, blur,
+// and sharpen.
+func (i *Image) filter(opts *Options) error {
+	if opts.Grayscale || opts.Tint != nil {
+		if err := i.reference.ToColorSpace(vips.InterpretationBW); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if opts.Tint != nil {
+		if err := i.tint(*opts.Tint); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if opts.Brightness != 0 || opts.Contrast != 0 || (opts.Gamma != 0 && opts.Gamma != 1) {
+		if err := i.adjust(opts.Brightness, opts.Contrast, opts.Gamma); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	// Blurring and sharpening sample neighbouring pixels, so frames of
+	// animated images are filtered on their own to keep them from bleeding
+	// into each other.
+	if opts.Blur > 0 {
+		err := i.eachFrame(func(frame *vips.ImageRef) error {
+			return frame.GaussianBlur(opts.Blur)
+		})
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if opts.Sharpen > 0 {
+		err := i.eachFrame(func(frame *vips.ImageRef) error {
+			return frame.Sharpen(opts.Sharpen, sharpenThreshold, sharpenAmount)
+		})
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	return nil
+}
+
+// tint colors a grayscale image with color, mapping black to black and white
+// to color.
+func (i *Image) tint(color Color) error {
+	if err := i.reference.ToColorSpace(vips.InterpretationSRGB); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	var (
+		multipliers = []float64{float64(color.R) / 255, float64(color.G) / 255, float64(color.B) / 255}
+		addends     = []float64{0, 0, 0}
+	)
+
+	if i.reference.HasAlpha() {
+		multipliers = append(multipliers, 1)
+		addends = append(addends, 0)
+	}
+
+	if err := i.reference.Linear(multipliers, addends); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := i.reference.Cast(vips.BandFormatUchar); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// adjust changes the brightness, contrast and gamma of the image through a
+// lookup table. The alpha channel is left untouched.
+func (i *Image) adjust(brightness, contrast, gamma float64) error {
+	if err := i.to8Bit(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	table, err := lookupTable(brightness, contrast, gamma)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	defer table.Close()
+
+	if !i.reference.HasAlpha() {
+		if err = i.reference.Maplut(table); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		return nil
+	}
+
+	bands := i.reference.Bands()
+
+	alpha, err := i.reference.ExtractBandToImage(bands-1, 1)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	defer alpha.Close()
+
+	if err = i.reference.ExtractBand(0, bands-1); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err = i.reference.Maplut(table); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err = i.reference.BandJoin(alpha); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// to8Bit converts a 16-bit image to 8 bits per band.
+func (i *Image) to8Bit() error {
+	if i.reference.BandFormat() != vips.BandFormatUshort {
+		return nil
+	}
+
+	if err := i.reference.Linear1(1.0/257, 0); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := i.reference.Cast(vips.BandFormatUchar); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// lookupTable returns a 256 entry libvips lookup table applying gamma, then
+// contrast, then brightness to 8-bit values. See Adjust.
+func lookupTable(brightness, contrast, gamma float64) (*vips.ImageRef, error) {
+	table := image.NewGray(image.Rect(0, 0, 256, 1))
+
+	for value := 0; value < len(table.Pix); value++ {
+		table.Pix[value] = Adjust(uint8(value), brightness, contrast, gamma)
+	}
+
+	var buffer bytes.Buffer
+
+	if err := png.Encode(&buffer, table); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	reference, err := vips.LoadImageFromBuffer(buffer.Bytes(), vips.NewImportParams())
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return reference, nil
+}
+
+// Adjust applies gamma correction, contrast and brightness, in that order, to
+// an 8-bit value.
+//
+// Gamma is applied as value^(1/gamma), so values above 1 lighten the
+// midtones; zero is treated as 1. Contrast, from -1 to 1, scales the distance
+// to the midpoint by 1+contrast. Brightness, from -1 to 1, is added as a
+// fraction of the full range.
+func Adjust(value uint8, brightness, contrast, gamma float64) uint8 {
+	x := float64(value) / 255
+
+	if gamma > 0 {
+		x = math.Pow(x, 1/gamma)
+	}
+
+	x = (x-0.5)*(1+contrast) + 0.5 + brightness
+
+	return uint8(math.Round(min(max(x, 0), 1) * 255))
+}
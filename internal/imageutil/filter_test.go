@@ -0,0 +1,71 @@
+package imageutil_test
+
+import (
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
+)
+
+func TestAdjust(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		value      uint8
+		brightness float64
+		contrast   float64
+		gamma      float64
+		want       uint8
+	}{
+		{
+			name:  "identity",
+			value: 100,
+			gamma: 1,
+			want:  100,
+		},
+		{
+			name:  "zero gamma is identity",
+			value: 100,
+			want:  100,
+		},
+		{
+			name:       "brightness",
+			value:      100,
+			brightness: 0.2,
+			gamma:      1,
+			want:       151,
+		},
+		{
+			name:       "brightness is clamped",
+			value:      250,
+			brightness: 0.5,
+			gamma:      1,
+			want:       255,
+		},
+		{
+			name:     "contrast",
+			value:    100,
+			contrast: 0.5,
+			gamma:    1,
+			want:     86,
+		},
+		{
+			name:  "gamma lightens midtones",
+			value: 64,
+			gamma: 2.2,
+			want:  136,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := imageutil.Adjust(tt.value, tt.brightness, tt.contrast, tt.gamma); got != tt.want {
+				t.Errorf("Adjust() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
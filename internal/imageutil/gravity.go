@@ -0,0 +1,97 @@
+package imageutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Gravity represents which part of an image is kept when it's cropped to
+// cover the requested dimensions, or where it's placed when letterboxed.
+type Gravity string
+
+// List of gravities supported by the service.
+const (
+	GravityCentre    Gravity = "centre"
+	GravityNorth     Gravity = "north"
+	GravityNorthEast Gravity = "north-east"
+	GravityEast      Gravity = "east"
+	GravitySouthEast Gravity = "south-east"
+	GravitySouth     Gravity = "south"
+	GravitySouthWest Gravity = "south-west"
+	GravityWest      Gravity = "west"
+	GravityNorthWest Gravity = "north-west"
+
+	// GravityAttention keeps the region most likely to draw human attention,
+	// based on skin tones, saturation and edges.
+	GravityAttention Gravity = "attention"
+
+	// GravityEntropy keeps the region with the highest entropy.
+	GravityEntropy Gravity = "entropy"
+)
+
+// Gravities is the list of gravities supported by the service.
+var Gravities = []Gravity{
+	GravityCentre,
+	GravityNorth,
+	GravityNorthEast,
+	GravityEast,
+	GravitySouthEast,
+	GravitySouth,
+	GravitySouthWest,
+	GravityWest,
+	GravityNorthWest,
+	GravityAttention,
+	GravityEntropy,
+}
+
+// ParseGravity parses the name of a gravity, ignoring case. The "center" and
+// "smart" aliases are accepted for GravityCentre and GravityAttention, and
+// compass directions may be written with or without a hyphen, e.g. "northeast".
+func ParseGravity(name string) (Gravity, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	switch name {
+	case "center":
+		return GravityCentre, nil
+	case "smart":
+		return GravityAttention, nil
+	}
+
+	for _, gravity := range Gravities {
+		if name == string(gravity) || name == strings.ReplaceAll(string(gravity), "-", "") {
+			return gravity, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedGravity, name)
+}
+
+// Smart reports whether the gravity is based on the content of the image
+// rather than on a fixed position.
+func (g Gravity) Smart() bool {
+	return g == GravityAttention || g == GravityEntropy
+}
+
+// offset returns the position of an inner rectangle placed inside an outer
+// one according to the gravity. Gravities that depend on the image content
+// are centered.
+func (g Gravity) offset(outerWidth, outerHeight, innerWidth, innerHeight int) (left, top int) {
+	left = (outerWidth - innerWidth) / 2
+	top = (outerHeight - innerHeight) / 2
+
+	switch g {
+	case GravityNorth, GravityNorthEast, GravityNorthWest:
+		top = 0
+	case GravitySouth, GravitySouthEast, GravitySouthWest:
+		top = outerHeight - innerHeight
+	}
+
+	switch g {
+	case GravityWest, GravityNorthWest, GravitySouthWest:
+		left = 0
+	case GravityEast, GravityNorthEast, GravitySouthEast:
+		left = outerWidth - innerWidth
+	}
+
+	return left, top
+}
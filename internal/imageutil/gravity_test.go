@@ -0,0 +1,67 @@
+package imageutil_test
+
+import (
+	"errors"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
+)
+
+func TestParseGravity(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		give    string
+		want    imageutil.Gravity
+		wantErr error
+	}{
+		{
+			name: "compass direction",
+			give: "South-East",
+			want: imageutil.GravitySouthEast,
+		},
+		{
+			name: "compass direction without hyphen",
+			give: "northwest",
+			want: imageutil.GravityNorthWest,
+		},
+		{
+			name: "center alias",
+			give: "center",
+			want: imageutil.GravityCentre,
+		},
+		{
+			name: "smart alias",
+			give: "smart",
+			want: imageutil.GravityAttention,
+		},
+		{
+			name: "entropy",
+			give: "entropy",
+			want: imageutil.GravityEntropy,
+		},
+		{
+			name:    "unsupported gravity",
+			give:    "up",
+			wantErr: imageutil.ErrUnsupportedGravity,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := imageutil.ParseGravity(tt.give)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseGravity() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseGravity() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package imageutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unicode/utf16"
+)
+
+// Layout of ICC profiles. See https://www.color.org/specification/ICC.1-2022-05.pdf.
+const (
+	// iccHeaderSize is the size of the header of an ICC profile, which is
+	// followed by the number of tags and the tag table.
+	iccHeaderSize int = 128
+
+	// iccTagSize is the size of an entry of the tag table: its signature,
+	// offset and size.
+	iccTagSize int = 12
+)
+
+// ICCDescription returns the description of an ICC profile, such as "sRGB
+// IEC61966-2.1" or "Display P3". It returns an empty string if the profile
+// has no readable description.
+//
+// Both the textDescriptionType of version 2 profiles and the
+// multiLocalizedUnicodeType of version 4 profiles are supported. For the
+// latter, the first localization is used.
+func ICCDescription(profile []byte) string {
+	if len(profile) < iccHeaderSize+4 {
+		return ""
+	}
+
+	count := int(binary.BigEndian.Uint32(profile[iccHeaderSize:]))
+
+	for index := 0; index < count; index++ {
+		entry := iccHeaderSize + 4 + index*iccTagSize
+		if entry+iccTagSize > len(profile) {
+			return ""
+		}
+
+		if string(profile[entry:entry+4]) != "desc" {
+			continue
+		}
+
+		var (
+			offset = int(binary.BigEndian.Uint32(profile[entry+4:]))
+			size   = int(binary.BigEndian.Uint32(profile[entry+8:]))
+		)
+
+		if offset < 0 || size < 0 || offset+size > len(profile) || offset+size < offset {
+			return ""
+		}
+
+		return iccText(profile[offset : offset+size])
+	}
+
+	return ""
+}
+
+// iccText decodes the text of an ICC textDescriptionType or
+// multiLocalizedUnicodeType tag.
+func iccText(tag []byte) string {
+	if len(tag) < 12 {
+		return ""
+	}
+
+	switch string(tag[:4]) {
+	case "desc":
+		length := int(binary.BigEndian.Uint32(tag[8:]))
+		if length < 0 || 12+length > len(tag) || 12+length < 12 {
+			return ""
+		}
+
+		text, _, _ := bytes.Cut(tag[12:12+length], []byte{0})
+
+		return string(text)
+	case "mluc":
+		if binary.BigEndian.Uint32(tag[8:]) == 0 || len(tag) < 28 {
+			return ""
+		}
+
+		var (
+			length = int(binary.BigEndian.Uint32(tag[20:]))
+			offset = int(binary.BigEndian.Uint32(tag[24:]))
+		)
+
+		if length < 0 || offset < 0 || offset+length > len(tag) || offset+length < offset {
+			return ""
+		}
+
+		units := make([]uint16, 0, length/2)
+
+		for i := offset; i+1 < offset+length; i += 2 {
+			units = append(units, binary.BigEndian.Uint16(tag[i:]))
+		}
+
+		return string(utf16.Decode(units))
+	default:
+		return ""
+	}
+}
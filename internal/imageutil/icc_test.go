@@ -0,0 +1,101 @@
+package imageutil_test
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
+)
+
+// iccProfile returns a minimal ICC profile holding a single tag.
+func iccProfile(signature string, tag []byte) []byte {
+	profile := make([]byte, 128+4+12)
+
+	binary.BigEndian.PutUint32(profile[128:], 1)
+	copy(profile[132:], signature)
+	binary.BigEndian.PutUint32(profile[136:], uint32(len(profile)))
+	binary.BigEndian.PutUint32(profile[140:], uint32(len(tag)))
+
+	return append(profile, tag...)
+}
+
+// textDescription returns a version 2 textDescriptionType tag.
+func textDescription(text string) []byte {
+	tag := make([]byte, 12)
+
+	copy(tag, "desc")
+	binary.BigEndian.PutUint32(tag[8:], uint32(len(text)+1))
+
+	return append(append(tag, text...), 0)
+}
+
+// multiLocalizedUnicode returns a version 4 multiLocalizedUnicodeType tag
+// with a single localization.
+func multiLocalizedUnicode(text string) []byte {
+	tag := make([]byte, 28)
+
+	copy(tag, "mluc")
+	binary.BigEndian.PutUint32(tag[8:], 1)
+	binary.BigEndian.PutUint32(tag[12:], 12)
+	copy(tag[16:], "enUS")
+
+	units := utf16.Encode([]rune(text))
+
+	binary.BigEndian.PutUint32(tag[20:], uint32(len(units)*2))
+	binary.BigEndian.PutUint32(tag[24:], 28)
+
+	for _, unit := range units {
+		tag = binary.BigEndian.AppendUint16(tag, unit)
+	}
+
+	return tag
+}
+
+func TestICCDescription(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give []byte
+		want string
+	}{
+		{
+			name: "version 2 description",
+			give: iccProfile("desc", textDescription("sRGB IEC61966-2.1")),
+			want: "sRGB IEC61966-2.1",
+		},
+		{
+			name: "version 4 description",
+			give: iccProfile("desc", multiLocalizedUnicode("Display P3")),
+			want: "Display P3",
+		},
+		{
+			name: "no description",
+			give: iccProfile("cprt", textDescription("Public domain")),
+			want: "",
+		},
+		{
+			name: "truncated tag",
+			give: iccProfile("desc", textDescription("Display P3"))[:150],
+			want: "",
+		},
+		{
+			name: "empty",
+			give: nil,
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := imageutil.ICCDescription(tt.give); got != tt.want {
+				t.Errorf("ICCDescription() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
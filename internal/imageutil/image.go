@@ -0,0 +1,648 @@
+package imageutil
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// Image represents an image being processed by the service.
+type Image struct {
+	// reference is the underlying libvips image.
+	reference *vips.ImageRef
+
+	// format is the format of the input image.
+	format Format
+
+	// svg is the sanitized source of SVG images.
+	svg []byte
+
+	// size is the size of the input image in bytes.
+	size int
+}
+
+// Open reads an image from r and decodes it.
+func Open(r io.Reader) (*Image, error) {
+	if r == nil {
+		return nil, fmt.Errorf("%w: %w", ErrOpenImage, ErrNilImage)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrOpenImage, err)
+	}
+
+	format, err := detectFormat(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrOpenImage, err)
+	}
+
+	var (
+		source = data
+		svg    []byte
+	)
+
+	if format == FormatSVG {
+		svg, err = SanitizeSVG(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrOpenImage, err)
+		}
+
+		source = svg
+	}
+
+	reference, err := load(source, format)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrOpenImage, err)
+	}
+
+	return &Image{
+		reference: reference,
+		format:    format,
+		svg:       svg,
+		size:      len(data),
+	}, nil
+}
+
+// load decodes an image in the given format. Every frame of animated images
+// is loaded.
+func load(data []byte, format Format) (*vips.ImageRef, error) {
+	params := vips.NewImportParams()
+
+	if format.Animated() {
+		params.NumPages.Set(-1)
+	}
+
+	reference, err := vips.LoadImageFromBuffer(data, params)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return reference, nil
+}
+
+// Close releases the resources associated with the image.
+func (i *Image) Close() {
+	if i != nil && i.reference != nil {
+		i.reference.Close()
+	}
+}
+
+// Format returns the format of the input image.
+func (i *Image) Format() Format {
+	return i.format
+}
+
+// Size returns the size of the input image in bytes.
+func (i *Image) Size() int {
+	return i.size
+}
+
+// Animated reports whether the image has more than one frame.
+func (i *Image) Animated() bool {
+	return i.reference.Pages() > 1
+}
+
+// Width returns the current width of the image.
+func (i *Image) Width() int {
+	return i.reference.Width()
+}
+
+// Height returns the current height of the image. For animated images, it's
+// the height of a single frame.
+func (i *Image) Height() int {
+	return i.reference.PageHeight()
+}
+
+// Process applies opts to the image and returns it encoded in the requested
+// output format.
+func (i *Image) Process(opts *Options) (*Result, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	format := opts.Format
+	if format == "" {
+		format = DefaultOutputFormat(i.format)
+	}
+
+	if format.Vector() {
+		return i.vector(format)
+	}
+
+	if i.format.Vector() && (opts.Width > 0 || opts.Height > 0) {
+		if err := i.rasterize(opts.Width, opts.Height); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	if i.Animated() && !format.Animated() {
+		if err := i.firstFrame(); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	if err := i.orient(opts); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if opts.Colorspace != "" {
+		if err := i.convertColorspace(opts.Colorspace, format); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	var focus *point
+
+	if opts.Focus != nil {
+		focus = &point{
+			x: opts.Focus.X.resolve(i.reference.Width()),
+			y: opts.Focus.Y.resolve(i.reference.PageHeight()),
+		}
+	}
+
+	if opts.Crop != nil {
+		origin, err := i.crop(*opts.Crop)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		if focus != nil {
+			focus.x -= origin.x
+			focus.y -= origin.y
+		}
+	}
+
+	if opts.Trim > 0 {
+		origin, err := i.trim(opts.Trim)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		if focus != nil {
+			focus.x -= origin.x
+			focus.y -= origin.y
+		}
+	}
+
+	if opts.Pad != nil {
+		origin, err := i.pad(*opts.Pad, opts.Background)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		if focus != nil {
+			focus.x += origin.x
+			focus.y += origin.y
+		}
+	}
+
+	switch {
+	case opts.Width > 0 || opts.Height > 0:
+		if err := i.resize(opts, focus); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	case opts.Pad != nil && i.exceeds(opts.MaxWidth, opts.MaxHeight):
+		limit := *opts
+		limit.Width, limit.Height, limit.Fit = opts.MaxWidth, opts.MaxHeight, FitInside
+
+		if err := i.resize(&limit, nil); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	if err := i.filter(opts); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if opts.Watermark != nil && len(opts.Watermark.Data) > 0 {
+		if err := i.watermark(opts.Watermark); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	if !format.Alpha() && i.reference.HasAlpha() {
+		if err := i.reference.Flatten(opts.Background.vipsRGB()); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	result := &Result{
+		Format:  format,
+		Quality: opts.Quality,
+		Width:   i.reference.Width(),
+		Height:  i.reference.PageHeight(),
+	}
+
+	strip, err := i.prepareExport(opts, format)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	switch {
+	case opts.TargetSSIM > 0:
+		// The reference is taken after the colors are prepared for encoding, so
+		// it's compared to candidates in the same color space.
+		var reference []byte
+
+		reference, err = luminance(i.reference)
+		if err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+
+		result.Data, result.Quality, result.SSIM, err = i.exportSimilar(opts, format, strip, reference)
+	case opts.MaxBytes > 0:
+		result.Data, result.Quality, err = i.exportWithin(opts, format, strip)
+	default:
+		result.Data, err = i.export(opts, format, opts.Quality, strip)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return result, nil
+}
+
+// exceeds reports whether the image is wider than width or taller than
+// height. A zero dimension is ignored.
+func (i *Image) exceeds(width, height uint) bool {
+	return (width > 0 && i.reference.Width() > int(width)) ||
+		(height > 0 && i.reference.PageHeight() > int(height))
+}
+
+// vector returns the sanitized source of an SVG image. Raster images cannot be
+// converted to a vector format.
+func (i *Image) vector(format Format) (*Result, error) {
+	if i.svg == nil {
+		return nil, fmt.Errorf("%w: %s to %s", ErrUnsupportedConversion, i.format, format)
+	}
+
+	return &Result{
+		Format: format,
+		Data:   i.svg,
+		Width:  i.reference.Width(),
+		Height: i.reference.Height(),
+	}, nil
+}
+
+// rasterize renders an SVG image again at a density that makes it at least
+// as large as the given dimensions, so it's never upscaled as a bitmap. A zero
+// dimension is ignored.
+func (i *Image) rasterize(width, height uint) error {
+	scale := max(
+		float64(width)/float64(i.reference.Width()),
+		float64(height)/float64(i.reference.Height()),
+	)
+
+	if scale <= 1 {
+		return nil
+	}
+
+	params := vips.NewImportParams()
+	params.Density.Set(int(math.Ceil(svgDensity * scale)))
+
+	reference, err := vips.LoadImageFromBuffer(i.svg, params)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	i.reference.Close()
+	i.reference = reference
+
+	return nil
+}
+
+// firstFrame discards every frame of an animated image but the first one.
+func (i *Image) firstFrame() error {
+	frameHeight := i.reference.PageHeight()
+
+	if err := i.reference.SetPageHeight(i.reference.Height()); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := i.reference.ExtractArea(0, 0, i.reference.Width(), frameHeight); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := i.reference.SetPages(1); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// eachFrame applies transform to every frame of an animated image on its own
+// and joins the frames back together, so operations that move pixels
+// vertically or sample their neighbours don't mix frames up. Images with a
+// single frame are transformed as a whole.
+func (i *Image) eachFrame(transform func(frame *vips.ImageRef) error) error {
+	pages := i.reference.Pages()
+	if pages <= 1 {
+		return transform(i.reference)
+	}
+
+	var (
+		frameHeight = i.reference.PageHeight()
+		frames      = make([]*vips.ImageRef, 0, pages)
+	)
+
+	defer func() {
+		for _, frame := range frames {
+			frame.Close()
+		}
+	}()
+
+	for page := 0; page < pages; page++ {
+		frame, err := i.reference.Copy()
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		frames = append(frames, frame)
+
+		if err = frame.SetPageHeight(frame.Height()); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if err = frame.ExtractArea(0, page*frameHeight, frame.Width(), frameHeight); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if err = frame.SetPages(1); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if err = transform(frame); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	strip := frames[0]
+	frameHeight = strip.Height()
+
+	if err := strip.ArrayJoin(frames[1:], 1); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := strip.SetPageHeight(frameHeight); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err := strip.SetPages(pages); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	frames = frames[1:]
+
+	i.reference.Close()
+	i.reference = strip
+
+	return nil
+}
+
+// exportWithin encodes the image using the highest quality, up to
+// opts.Quality, whose output is not larger than opts.MaxBytes. The quality is
+// found with a binary search, so the image is encoded about log2(opts.Quality)
+// times.
+func (i *Image) exportWithin(opts *Options, format Format, strip bool) (image []byte, quality uint, err error) {
+	var (
+		low  uint = 1
+		high      = opts.Quality
+	)
+
+	for low <= high {
+		middle := low + (high-low)/2
+
+		data, err := i.export(opts, format, middle, strip)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if len(data) <= opts.MaxBytes {
+			image, quality = data, middle
+			low = middle + 1
+		} else {
+			high = middle - 1
+		}
+	}
+
+	if image == nil {
+		return nil, 0, fmt.Errorf("%w: %d bytes", ErrMaxBytesExceeded, opts.MaxBytes)
+	}
+
+	return image, quality, nil
+}
+
+// exportSimilar encodes the image using the lowest quality whose output has a
+// structural similarity to the unencoded image, whose luminance is reference,
+// of at least opts.TargetSSIM. If no quality meets the target, the highest
+// quality is used.
+func (i *Image) exportSimilar(opts *Options, format Format, strip bool, reference []byte) (image []byte, quality uint, score float64, err error) {
+	var (
+		width       = i.reference.Width()
+		height      = i.reference.Height()
+		low    uint = 1
+		high   uint = MaxQuality
+		best   []byte
+	)
+
+	for low <= high {
+		middle := low + (high-low)/2
+
+		data, err := i.export(opts, format, middle, strip)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+
+		similar, err := similarity(data, format, reference, width, height)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+
+		if similar >= opts.TargetSSIM {
+			image, quality, score = data, middle, similar
+			high = middle - 1
+		} else {
+			low = middle + 1
+		}
+
+		if middle == MaxQuality {
+			best = data
+			score = similar
+		}
+	}
+
+	if image == nil {
+		return best, MaxQuality, score, nil
+	}
+
+	return image, quality, score, nil
+}
+
+// prepareExport converts the ICC profile of the image, removes the metadata
+// opts doesn't keep and quantizes the alpha channel of lossy WebP images
+// before it's encoded in format. It returns whether the encoder should strip
+// the remaining metadata.
+//
+// It changes the image, so it's called once before encoding rather than for
+// every quality tried by exportWithin and exportSimilar.
+func (i *Image) prepareExport(opts *Options, format Format) (strip bool, err error) {
+	var (
+		wide = wideGamut(opts.Colorspace, format)
+		keep = opts.Keep
+	)
+
+	strip = opts.StripMetadata
+
+	if opts.OptimizeICCProfile && !wide {
+		if err = i.reference.OptimizeICCProfile(); err != nil {
+			return false, fmt.Errorf("%w: %w", ErrExportImage, err)
+		}
+	}
+
+	// Display P3 images are meaningless without their profile.
+	if wide && (strip || len(keep) > 0) {
+		strip, keep = false, append(keep[:len(keep):len(keep)], MetadataICC)
+	}
+
+	if !strip && len(keep) > 0 {
+		if err = i.keepMetadata(keep); err != nil {
+			return false, fmt.Errorf("%w: %w", ErrExportImage, err)
+		}
+	}
+
+	if levels := AlphaLevels(opts.AlphaQuality); format == FormatWebP && !opts.Lossless && levels > 0 && i.reference.HasAlpha() {
+		if err = i.quantizeAlpha(levels); err != nil {
+			return false, fmt.Errorf("%w: %w", ErrExportImage, err)
+		}
+	}
+
+	return strip, nil
+}
+
+// quantizeAlpha rounds the alpha channel of the image to the given number of
+// evenly spaced levels.
+func (i *Image) quantizeAlpha(levels int) error {
+	var (
+		bands   = i.reference.Bands()
+		format  = i.reference.BandFormat()
+		maximum = 255.0
+	)
+
+	if format == vips.BandFormatUshort {
+		maximum = 65535
+	}
+
+	step := maximum / float64(levels-1)
+
+	alpha, err := i.reference.Copy()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	defer alpha.Close()
+
+	if err = alpha.ExtractBand(bands-1, 1); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	// Casting to an integer format truncates, so 0.5 is added to round to
+	// the nearest level and back to the nearest value.
+	if err = alpha.Linear([]float64{1 / step}, []float64{0.5}); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err = alpha.Cast(vips.BandFormatUchar); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err = alpha.Linear([]float64{step}, []float64{0.5}); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err = alpha.Cast(format); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err = i.reference.ExtractBand(0, bands-1); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if err = i.reference.BandJoin(alpha); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// export encodes the image in the given format and quality using opts,
+// stripping its metadata if strip is set. It doesn't change the image.
+func (i *Image) export(opts *Options, format Format, quality uint, strip bool) ([]byte, error) {
+	var (
+		image []byte
+		err   error
+	)
+
+	switch format {
+	case FormatJPEG:
+		image, _, err = i.reference.ExportJpeg(&vips.JpegExportParams{
+			StripMetadata:      strip,
+			Quality:            int(quality),
+			Interlace:          opts.Progressive,
+			SubsampleMode:      opts.Subsampling.vips(),
+			OptimizeCoding:     opts.OptimizeCoding,
+			TrellisQuant:       opts.TrellisQuant,
+			OvershootDeringing: opts.OvershootDeringing,
+			OptimizeScans:      opts.OptimizeScans,
+			QuantTable:         int(opts.QuantTable),
+		})
+	case FormatPNG:
+		image, _, err = i.reference.ExportPng(&vips.PngExportParams{
+			StripMetadata: strip,
+			Compression:   int(opts.Compression),
+			Interlace:     opts.Interlaced,
+			Quality:       int(quality),
+			Palette:       opts.Palette,
+			Dither:        max(opts.Dither, minDither),
+			Bitdepth:      PaletteBitdepth(opts.Palette, opts.Colors),
+		})
+	case FormatWebP:
+		image, _, err = i.reference.ExportWebp(&vips.WebpExportParams{
+			StripMetadata:   strip,
+			Quality:         int(quality),
+			Lossless:        opts.Lossless,
+			NearLossless:    opts.NearLossless,
+			ReductionEffort: int(min(opts.Effort, MaxWebPEffort)),
+		})
+
+		if err == nil && !strip && i.reference.HasICCProfile() {
+			image, err = EmbedWebPProfile(image, i.reference.GetICCProfile())
+		}
+	case FormatAVIF:
+		image, _, err = i.reference.ExportAvif(&vips.AvifExportParams{
+			StripMetadata: strip,
+			Quality:       int(quality),
+			Bitdepth:      8,
+			Effort:        int(opts.Effort),
+			Lossless:      opts.Lossless,
+		})
+	case FormatGIF:
+		image, _, err = i.reference.ExportGIF(&vips.GifExportParams{
+			StripMetadata: strip,
+			Quality:       int(quality),
+			Dither:        1,
+			Effort:        int(min(max(opts.Effort, MinGIFEffort), MaxGIFEffort)),
+			Bitdepth:      8,
+		})
+	default:
+		return nil, fmt.Errorf("%w: %w: %s", ErrExportImage, ErrUnsupportedImageFormat, format)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrExportImage, err)
+	}
+
+	return image, nil
+}
@@ -0,0 +1,298 @@
+// Package imageutil implements the image processing pipeline used by the
+// service on top of libvips.
+package imageutil
+
+import (
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/xstd-go/xerrors"
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+const (
+	// ErrOpenImage is returned when an image cannot be opened.
+	ErrOpenImage xerrors.Error = "failed to open image"
+
+	// ErrNilImage is returned when the image reader is nil.
+	ErrNilImage xerrors.Error = "image is nil"
+
+	// ErrExportImage is returned when an image cannot be encoded.
+	ErrExportImage xerrors.Error = "failed to export image"
+
+	// ErrMaxBytesExceeded is returned when an image cannot be encoded within
+	// the requested size.
+	ErrMaxBytesExceeded xerrors.Error = "image cannot be encoded within the maximum size"
+
+	// ErrUnsupportedFit is returned when a fit mode is not supported by the
+	// service.
+	ErrUnsupportedFit xerrors.Error = "unsupported fit mode"
+
+	// ErrUnsupportedGravity is returned when a gravity is not supported by the
+	// service.
+	ErrUnsupportedGravity xerrors.Error = "unsupported gravity"
+
+	// ErrUnsupportedRotation is returned when a rotation is not a multiple of
+	// 90 degrees.
+	ErrUnsupportedRotation xerrors.Error = "unsupported rotation"
+
+	// ErrUnsupportedFlip is returned when a flip direction is not supported
+	// by the service.
+	ErrUnsupportedFlip xerrors.Error = "unsupported flip direction"
+
+	// ErrUnsupportedColors is returned when a number of colors is not a
+	// palette size supported by PNG.
+	ErrUnsupportedColors xerrors.Error = "unsupported number of colors"
+
+	// ErrUnsupportedSubsampling is returned when a chroma subsampling mode is
+	// not supported by the service.
+	ErrUnsupportedSubsampling xerrors.Error = "unsupported chroma subsampling"
+
+	// ErrSubsamplingFormat is returned when a chroma subsampling mode is
+	// requested together with an output format other than JPEG. govips only
+	// exposes the chroma subsampling of the JPEG encoder.
+	ErrSubsamplingFormat xerrors.Error = "chroma subsampling is only supported for JPEG images"
+
+	// ErrUnsupportedMetadata is returned when a kind of metadata is not
+	// supported by the service.
+	ErrUnsupportedMetadata xerrors.Error = "unsupported metadata"
+
+	// ErrUnsupportedColorspace is returned when a color space is not
+	// supported by the service.
+	ErrUnsupportedColorspace xerrors.Error = "unsupported color space"
+
+	// ErrInvalidWatermark is returned when a watermark image cannot be
+	// decoded.
+	ErrInvalidWatermark xerrors.Error = "invalid watermark"
+
+	// ErrInvalidRegion is returned when a crop region cannot be parsed or is
+	// outside the image.
+	ErrInvalidRegion xerrors.Error = "invalid region"
+
+	// ErrInvalidPoint is returned when a point cannot be parsed.
+	ErrInvalidPoint xerrors.Error = "invalid point"
+
+	// ErrInvalidPadding is returned when padding cannot be parsed.
+	ErrInvalidPadding xerrors.Error = "invalid padding"
+
+	// ErrInvalidColor is returned when a color cannot be parsed.
+	ErrInvalidColor xerrors.Error = "invalid color"
+
+	// ErrEncodeBlurHash is returned when a BlurHash cannot be computed from
+	// the given pixels.
+	ErrEncodeBlurHash xerrors.Error = "failed to encode BlurHash"
+
+	// ErrInvalidWebP is returned when a WebP image cannot be parsed.
+	ErrInvalidWebP xerrors.Error = "invalid WebP image"
+
+	// ErrInvalidSVG is returned when an SVG document cannot be parsed.
+	ErrInvalidSVG xerrors.Error = "invalid SVG document"
+
+	// ErrUnsupportedConversion is returned when an image cannot be encoded in
+	// the requested format, such as a raster image as SVG.
+	ErrUnsupportedConversion xerrors.Error = "unsupported conversion"
+
+	// ErrUnsupportedImageFormat is returned when the format of an image is not
+	// supported by the service.
+	ErrUnsupportedImageFormat xerrors.Error = "unsupported image format"
+)
+
+// Format represents an image format known to the service.
+type Format string
+
+// List of image formats known to the service.
+const (
+	FormatJPEG Format = "jpeg"
+	FormatPNG  Format = "png"
+	FormatWebP Format = "webp"
+	FormatAVIF Format = "avif"
+	FormatGIF  Format = "gif"
+	FormatHEIF Format = "heif"
+	FormatSVG  Format = "svg"
+
+	// FormatAuto is a pseudo-format telling the service to pick the best
+	// output format supported by the client. See NegotiateFormat.
+	FormatAuto Format = "auto"
+)
+
+// InputFormats is the list of formats the service can decode.
+var InputFormats = []Format{
+	FormatJPEG,
+	FormatPNG,
+	FormatGIF,
+	FormatWebP,
+	FormatHEIF,
+	FormatAVIF,
+	FormatSVG,
+}
+
+// OutputFormats is the list of formats the service can encode.
+var OutputFormats = []Format{
+	FormatJPEG,
+	FormatPNG,
+	FormatWebP,
+	FormatAVIF,
+	FormatGIF,
+	FormatSVG,
+}
+
+// ParseFormat parses the name of an output format, ignoring case. The "jpg"
+// alias is accepted for JPEG, and "auto" is accepted as FormatAuto.
+func ParseFormat(name string) (Format, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	switch name {
+	case "jpg":
+		return FormatJPEG, nil
+	case string(FormatAuto):
+		return FormatAuto, nil
+	}
+
+	for _, format := range OutputFormats {
+		if Format(name) == format {
+			return format, nil
+		}
+	}
+
+	return "", ErrUnsupportedImageFormat
+}
+
+// DefaultOutputFormat returns the format an image in the input format is
+// encoded to when no output format is requested: the input format itself if
+// the service can encode it, or JPEG otherwise.
+func DefaultOutputFormat(input Format) Format {
+	for _, format := range OutputFormats {
+		if format == input {
+			return format
+		}
+	}
+
+	return FormatJPEG
+}
+
+// Animated reports whether the format can hold animated images.
+func (f Format) Animated() bool {
+	return f == FormatGIF || f == FormatWebP
+}
+
+// Alpha reports whether the format can hold transparency.
+func (f Format) Alpha() bool {
+	return f != FormatJPEG
+}
+
+// Vector reports whether the format holds vector images.
+func (f Format) Vector() bool {
+	return f == FormatSVG
+}
+
+// MIMEType returns the media type of the format.
+func (f Format) MIMEType() string {
+	if f == FormatSVG {
+		return "image/svg+xml"
+	}
+
+	return "image/" + string(f)
+}
+
+// Extension returns the canonical file extension of the format, including
+// the leading dot.
+func (f Format) Extension() string {
+	switch f {
+	case FormatJPEG:
+		return ".jpg"
+	case FormatHEIF:
+		return ".heic"
+	}
+
+	return "." + string(f)
+}
+
+// FormatList returns a human-readable, comma-separated list of formats, e.g.
+// "JPEG, PNG, or WEBP".
+func FormatList(formats []Format) string {
+	names := make([]string, 0, len(formats))
+
+	for _, format := range formats {
+		names = append(names, strings.ToUpper(string(format)))
+	}
+
+	switch len(names) {
+	case 0:
+		return ""
+	case 1:
+		return names[0]
+	case 2:
+		return names[0] + " or " + names[1]
+	default:
+		return strings.Join(names[:len(names)-1], ", ") + ", or " + names[len(names)-1]
+	}
+}
+
+// detectFormat detects the format of an encoded image based on its magic
+// bytes.
+func detectFormat(data []byte) (Format, error) {
+	switch vips.DetermineImageType(data) {
+	case vips.ImageTypeJPEG:
+		return FormatJPEG, nil
+	case vips.ImageTypePNG:
+		return FormatPNG, nil
+	case vips.ImageTypeGIF:
+		return FormatGIF, nil
+	case vips.ImageTypeWEBP:
+		return FormatWebP, nil
+	case vips.ImageTypeHEIF:
+		return FormatHEIF, nil
+	case vips.ImageTypeAVIF:
+		return FormatAVIF, nil
+	case vips.ImageTypeSVG:
+		return FormatSVG, nil
+	default:
+		return "", ErrUnsupportedImageFormat
+	}
+}
+
+// NegotiateFormat picks the best output format supported by a client based on
+// the value of its Accept header, preferring AVIF over WebP. If animated is
+// true, only formats that can hold animated images are considered. If the
+// client supports none, or if fallback is a vector format, fallback is
+// returned.
+//
+// Wildcards are ignored since browsers send "image/*" without being able to
+// decode every image format.
+func NegotiateFormat(accept string, fallback Format, animated bool) Format {
+	if fallback.Vector() {
+		return fallback
+	}
+
+	accepted := make(map[string]bool)
+
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(mediaRange, ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+		accepted[mediaType] = true
+
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(param, "=")
+			if strings.TrimSpace(key) != "q" {
+				continue
+			}
+
+			if quality, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil || quality <= 0 {
+				accepted[mediaType] = false
+			}
+		}
+	}
+
+	for _, format := range []Format{FormatAVIF, FormatWebP} {
+		if animated && !format.Animated() {
+			continue
+		}
+
+		if accepted[format.MIMEType()] {
+			return format
+		}
+	}
+
+	return fallback
+}
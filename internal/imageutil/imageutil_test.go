@@ -0,0 +1,205 @@
+package imageutil_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	imageutil.Startup(zap.NewNop())
+
+	code := m.Run()
+
+	imageutil.Shutdown()
+
+	os.Exit(code)
+}
+
+func TestParseFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		give    string
+		want    imageutil.Format
+		wantErr error
+	}{
+		{
+			name: "JPEG",
+			give: "jpeg",
+			want: imageutil.FormatJPEG,
+		},
+		{
+			name: "JPG alias",
+			give: "JPG",
+			want: imageutil.FormatJPEG,
+		},
+		{
+			name: "WebP",
+			give: " WebP ",
+			want: imageutil.FormatWebP,
+		},
+		{
+			name: "auto",
+			give: "auto",
+			want: imageutil.FormatAuto,
+		},
+		{
+			name:    "unsupported format",
+			give:    "bmp",
+			wantErr: imageutil.ErrUnsupportedImageFormat,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := imageutil.ParseFormat(tt.give)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseFormat() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatList(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give []imageutil.Format
+		want string
+	}{
+		{
+			name: "empty",
+			give: nil,
+			want: "",
+		},
+		{
+			name: "single format",
+			give: []imageutil.Format{imageutil.FormatPNG},
+			want: "PNG",
+		},
+		{
+			name: "two formats",
+			give: []imageutil.Format{imageutil.FormatJPEG, imageutil.FormatPNG},
+			want: "JPEG or PNG",
+		},
+		{
+			name: "three formats",
+			give: []imageutil.Format{imageutil.FormatJPEG, imageutil.FormatPNG, imageutil.FormatWebP},
+			want: "JPEG, PNG, or WEBP",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := imageutil.FormatList(tt.give); got != tt.want {
+				t.Errorf("FormatList() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		accept   string
+		animated bool
+		want     imageutil.Format
+	}{
+		{
+			name:   "empty header",
+			accept: "",
+			want:   imageutil.FormatJPEG,
+		},
+		{
+			name:   "browser with AVIF and WebP support",
+			accept: "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8",
+			want:   imageutil.FormatAVIF,
+		},
+		{
+			name:   "browser with WebP support",
+			accept: "image/webp,*/*",
+			want:   imageutil.FormatWebP,
+		},
+		{
+			name:   "AVIF explicitly refused",
+			accept: "image/avif;q=0, image/webp;q=0.9",
+			want:   imageutil.FormatWebP,
+		},
+		{
+			name:     "animated image",
+			accept:   "image/avif,image/webp,*/*",
+			animated: true,
+			want:     imageutil.FormatWebP,
+		},
+		{
+			name:   "wildcards only",
+			accept: "image/*,*/*;q=0.8",
+			want:   imageutil.FormatJPEG,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := imageutil.NegotiateFormat(tt.accept, imageutil.FormatJPEG, tt.animated); got != tt.want {
+				t.Errorf("NegotiateFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultOutputFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give imageutil.Format
+		want imageutil.Format
+	}{
+		{
+			name: "encodable format",
+			give: imageutil.FormatPNG,
+			want: imageutil.FormatPNG,
+		},
+		{
+			name: "decode-only format",
+			give: imageutil.FormatHEIF,
+			want: imageutil.FormatJPEG,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := imageutil.DefaultOutputFormat(tt.give); got != tt.want {
+				t.Errorf("DefaultOutputFormat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
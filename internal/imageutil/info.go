@@ -0,0 +1,100 @@
+package imageutil
+
+import "github.com/davidbyttow/govips/v2/vips"
+
+// interpretations maps libvips interpretations to the names of the color
+// spaces they represent, as used by libvips itself.
+var interpretations = map[vips.Interpretation]string{
+	vips.InterpretationMultiband: "multiband",
+	vips.InterpretationBW:        "b-w",
+	vips.InterpretationHistogram: "histogram",
+	vips.InterpretationXYZ:       "xyz",
+	vips.InterpretationLAB:       "lab",
+	vips.InterpretationCMYK:      "cmyk",
+	vips.InterpretationLABQ:      "labq",
+	vips.InterpretationRGB:       "rgb",
+	vips.InterpretationRGB16:     "rgb16",
+	vips.InterpretationCMC:       "cmc",
+	vips.InterpretationLCH:       "lch",
+	vips.InterpretationLABS:      "labs",
+	vips.InterpretationSRGB:      "srgb",
+	vips.InterpretationYXY:       "yxy",
+	vips.InterpretationFourier:   "fourier",
+	vips.InterpretationGrey16:    "grey16",
+	vips.InterpretationMatrix:    "matrix",
+	vips.InterpretationScRGB:     "scrgb",
+	vips.InterpretationHSV:       "hsv",
+}
+
+// bitDepths maps libvips band formats to the number of bits per band.
+var bitDepths = map[vips.BandFormat]int{
+	vips.BandFormatUchar:     8,
+	vips.BandFormatChar:      8,
+	vips.BandFormatUshort:    16,
+	vips.BandFormatShort:     16,
+	vips.BandFormatUint:      32,
+	vips.BandFormatInt:       32,
+	vips.BandFormatFloat:     32,
+	vips.BandFormatComplex:   64,
+	vips.BandFormatDouble:    64,
+	vips.BandFormatDpComplex: 128,
+}
+
+// Info describes an image as it was decoded, before any processing.
+type Info struct {
+	// Format is the format of the image.
+	Format Format
+
+	// Colorspace is the libvips name of the color space of the image, such as
+	// "srgb", "b-w" or "cmyk".
+	Colorspace string
+
+	// ICCProfile is the description of the ICC profile embedded in the image,
+	// if any.
+	ICCProfile string
+
+	// Width is the width of the image.
+	Width int
+
+	// Height is the height of the image. For animated images, it's the height
+	// of a single frame.
+	Height int
+
+	// Frames is the number of frames in the image.
+	Frames int
+
+	// BitDepth is the number of bits per band.
+	BitDepth int
+
+	// Orientation is the EXIF orientation of the image, from 1 to 8, or zero
+	// if the image has none.
+	Orientation int
+
+	// Size is the size of the image in bytes.
+	Size int
+
+	// Alpha reports whether the image has an alpha channel.
+	Alpha bool
+}
+
+// Info returns the description of the image. It must be called before the
+// image is processed.
+func (i *Image) Info() *Info {
+	info := &Info{
+		Format:      i.format,
+		Colorspace:  interpretations[i.reference.Interpretation()],
+		Width:       i.reference.Width(),
+		Height:      i.reference.PageHeight(),
+		Frames:      i.reference.Pages(),
+		BitDepth:    bitDepths[i.reference.BandFormat()],
+		Orientation: i.reference.Orientation(),
+		Size:        i.size,
+		Alpha:       i.reference.HasAlpha(),
+	}
+
+	if i.reference.HasICCProfile() {
+		info.ICCProfile = ICCDescription(i.reference.GetICCProfile())
+	}
+
+	return info
+}
@@ -0,0 +1,179 @@
+package imageutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// exifField is the libvips image field holding the raw EXIF block.
+const exifField string = "exif-data"
+
+// Metadata represents a kind of metadata that can be kept in an image.
+type Metadata string
+
+// List of kinds of metadata supported by the service.
+const (
+	// MetadataAll keeps every kind of metadata.
+	MetadataAll Metadata = "all"
+
+	// MetadataCopyright keeps the EXIF copyright notice.
+	MetadataCopyright Metadata = "copyright"
+
+	// MetadataCreator keeps the EXIF artist, i.e. the creator of the image.
+	MetadataCreator Metadata = "creator"
+
+	// MetadataICC keeps the ICC color profile.
+	MetadataICC Metadata = "icc"
+
+	// MetadataOrientation keeps the EXIF orientation.
+	MetadataOrientation Metadata = "orientation"
+
+	// MetadataEXIF keeps every EXIF field except the GPS ones, such as the
+	// camera model and serial number.
+	MetadataEXIF Metadata = "exif"
+
+	// MetadataGPS keeps the EXIF GPS fields.
+	MetadataGPS Metadata = "gps"
+
+	// MetadataXMP keeps the XMP packet as is.
+	MetadataXMP Metadata = "xmp"
+
+	// MetadataIPTC keeps the IPTC block as is.
+	MetadataIPTC Metadata = "iptc"
+)
+
+// Metadatas is the list of kinds of metadata supported by the service.
+var Metadatas = []Metadata{
+	MetadataAll,
+	MetadataCopyright,
+	MetadataCreator,
+	MetadataICC,
+	MetadataOrientation,
+	MetadataEXIF,
+	MetadataGPS,
+	MetadataXMP,
+	MetadataIPTC,
+}
+
+// ParseMetadata parses a comma-separated list of kinds of metadata, ignoring
+// case and duplicates.
+func ParseMetadata(list string) ([]Metadata, error) {
+	var (
+		seen = make(map[Metadata]bool)
+		kept []Metadata
+	)
+
+	for _, name := range strings.Split(list, ",") {
+		metadata := Metadata(strings.ToLower(strings.TrimSpace(name)))
+
+		if !validMetadata(metadata) {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedMetadata, name)
+		}
+
+		if !seen[metadata] {
+			seen[metadata] = true
+			kept = append(kept, metadata)
+		}
+	}
+
+	return kept, nil
+}
+
+// validMetadata reports whether metadata is a kind of metadata supported by
+// the service.
+func validMetadata(metadata Metadata) bool {
+	for _, m := range Metadatas {
+		if m == metadata {
+			return true
+		}
+	}
+
+	return false
+}
+
+// metadataKind returns the kind of metadata a libvips image field holds. It
+// returns false if the field is not metadata, or is handled separately like
+// the ICC profile and orientation.
+func metadataKind(field string) (Metadata, bool) {
+	switch {
+	case field == "exif-ifd0-Copyright":
+		return MetadataCopyright, true
+	case field == "exif-ifd0-Artist":
+		return MetadataCreator, true
+	case field == "exif-ifd0-Orientation":
+		return MetadataOrientation, true
+	case strings.HasPrefix(field, "exif-ifd3-"):
+		return MetadataGPS, true
+	case strings.HasPrefix(field, "exif-"):
+		return MetadataEXIF, true
+	case field == "xmp-data":
+		return MetadataXMP, true
+	case field == "iptc-data":
+		return MetadataIPTC, true
+	default:
+		return "", false
+	}
+}
+
+// keepMetadata removes every kind of metadata not listed in keep from the
+// image.
+//
+// EXIF fields are removed individually and libvips rebuilds the EXIF block
+// from the remaining ones when encoding the image, so the raw EXIF block is
+// kept whenever any EXIF field is.
+func (i *Image) keepMetadata(keep []Metadata) error {
+	kept := make(map[Metadata]bool, len(keep))
+
+	for _, metadata := range keep {
+		kept[metadata] = true
+	}
+
+	if kept[MetadataAll] {
+		return nil
+	}
+
+	if !kept[MetadataICC] {
+		if err := i.reference.RemoveICCProfile(); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if !kept[MetadataOrientation] {
+		if err := i.reference.RemoveOrientation(); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	var (
+		fields = i.reference.GetFields()
+		retain = make([]string, 0, len(fields))
+		exif   bool
+	)
+
+	for _, field := range fields {
+		if field == exifField {
+			continue
+		}
+
+		metadata, ok := metadataKind(field)
+		if ok && !kept[metadata] {
+			continue
+		}
+
+		if ok && strings.HasPrefix(field, "exif-") {
+			exif = true
+		}
+
+		retain = append(retain, field)
+	}
+
+	if exif {
+		retain = append(retain, exifField)
+	}
+
+	if err := i.reference.RemoveMetadata(retain...); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package imageutil_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
+)
+
+func TestParseMetadata(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		give    string
+		want    []imageutil.Metadata
+		wantErr error
+	}{
+		{
+			name: "single kind",
+			give: "icc",
+			want: []imageutil.Metadata{imageutil.MetadataICC},
+		},
+		{
+			name: "list with spaces, case and duplicates",
+			give: "Copyright, icc ,orientation,copyright",
+			want: []imageutil.Metadata{
+				imageutil.MetadataCopyright,
+				imageutil.MetadataICC,
+				imageutil.MetadataOrientation,
+			},
+		},
+		{
+			name:    "unsupported kind",
+			give:    "copyright,serial",
+			wantErr: imageutil.ErrUnsupportedMetadata,
+		},
+		{
+			name:    "empty item",
+			give:    "icc,",
+			wantErr: imageutil.ErrUnsupportedMetadata,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := imageutil.ParseMetadata(tt.give)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseMetadata() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMetadata() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
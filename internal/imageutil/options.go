@@ -0,0 +1,229 @@
+package imageutil
+
+const (
+	// MaxQuality is the maximum quality supported by the encoders.
+	MaxQuality uint = 100
+
+	// MaxEffort is the maximum CPU effort supported by the encoders.
+	MaxEffort uint = 9
+
+	// MaxWebPEffort is the maximum CPU effort supported by the WebP encoder.
+	MaxWebPEffort uint = 6
+
+	// MinGIFEffort is the minimum CPU effort supported by the GIF encoder.
+	MinGIFEffort uint = 1
+
+	// MaxGIFEffort is the maximum CPU effort supported by the GIF encoder.
+	MaxGIFEffort uint = 10
+)
+
+// Options represents the options used to process an image.
+type Options struct {
+	// Quality is the quality of the output image, from 1 to 100.
+	Quality uint
+
+	// Compression is the compression level of PNG images, from 0 to 9.
+	Compression uint
+
+	// QuantTable is the quantization table used for JPEG images, from 0 to 8.
+	QuantTable uint
+
+	// OptimizeCoding enables optimized Huffman coding for JPEG images.
+	OptimizeCoding bool
+
+	// Interlaced enables interlacing for PNG images.
+	Interlaced bool
+
+	// StripMetadata removes the metadata of the image, except for what Keep
+	// lists.
+	StripMetadata bool
+
+	// OptimizeICCProfile converts the image to a compact sRGB ICC profile.
+	OptimizeICCProfile bool
+
+	// TrellisQuant enables trellis quantization for JPEG images.
+	TrellisQuant bool
+
+	// OvershootDeringing enables overshoot deringing for JPEG images.
+	OvershootDeringing bool
+
+	// OptimizeScans splits the spectrum of progressive JPEG images into
+	// separate scans.
+	OptimizeScans bool
+
+	// AlphaQuality is the quality of the alpha channel of lossy WebP images,
+	// from 1 to 100. Values below 100 quantize the alpha channel to fewer
+	// levels before encoding; see AlphaLevels. If zero, 100 is used.
+	AlphaQuality uint
+
+	// Format is the output format. If empty, DefaultOutputFormat is used.
+	Format Format
+
+	// AutoOrient rotates the image upright based on its EXIF orientation
+	// before any other operation, so the orientation is kept even when the
+	// metadata is stripped.
+	AutoOrient bool
+
+	// Rotate is the clockwise rotation to apply to the image, in degrees. It
+	// must be 0, 90, 180 or 270.
+	Rotate uint
+
+	// Flip is the direction to mirror the image in. If empty, the image is
+	// not mirrored.
+	Flip Flip
+
+	// Crop is the region of the image to extract before resizing, relative to
+	// the oriented image. If nil, the whole image is used.
+	Crop *Region
+
+	// Focus is the focal point of the image, relative to the oriented image
+	// before cropping. If set, it takes precedence over Gravity and the crop
+	// done by FitCover is centered on it as much as possible.
+	Focus *Point
+
+	// Trim is the threshold used to remove uniform borders from the image
+	// after cropping, i.e. how much the borders can differ from the color of
+	// the top-left pixel. If zero, the image is not trimmed.
+	Trim float64
+
+	// Pad is the padding added around the image after trimming, filled with
+	// Background. If nil, the image is not padded.
+	Pad *Padding
+
+	// MaxWidth is the maximum width of a padded image when Width and Height
+	// are zero. Larger images are shrunk to fit.
+	MaxWidth uint
+
+	// MaxHeight is the maximum height of a padded image when Width and Height
+	// are zero. Larger images are shrunk to fit.
+	MaxHeight uint
+
+	// Width is the width to resize the image to. If zero, it's calculated from
+	// Height while keeping the aspect ratio.
+	Width uint
+
+	// Height is the height to resize the image to. If zero, it's calculated
+	// from Width while keeping the aspect ratio.
+	Height uint
+
+	// Fit controls how the image is resized when both Width and Height are
+	// set. If empty, FitCover is used.
+	Fit Fit
+
+	// Gravity controls which part of the image is kept when Fit is FitCover,
+	// and where the image is placed when Fit is FitContain. If empty,
+	// GravityCentre is used.
+	Gravity Gravity
+
+	// Background is the color used to fill the letterbox when Fit is
+	// FitContain and the padding, and to flatten transparent images onto when the output
+	// format cannot hold transparency. Its alpha channel is ignored when
+	// flattening.
+	Background Color
+
+	// Grayscale converts the image to grayscale.
+	Grayscale bool
+
+	// Tint, if set, converts the image to grayscale and colors it with Tint,
+	// mapping white to the tint color.
+	Tint *Color
+
+	// Brightness is added to every value of the image as a fraction of the
+	// full range, from -1 to 1.
+	Brightness float64
+
+	// Contrast scales the distance between every value of the image and the
+	// midpoint by 1+Contrast, from -1 to 1.
+	Contrast float64
+
+	// Gamma is the gamma correction applied to the image, where values above
+	// 1 lighten the midtones. If zero, it's treated as 1.
+	Gamma float64
+
+	// Blur is the sigma of the Gaussian blur applied to the image after
+	// resizing. If zero, the image is not blurred.
+	Blur float64
+
+	// Sharpen is the sigma of the sharpening applied to the image after
+	// resizing. If zero, the image is not sharpened.
+	Sharpen float64
+
+	// Watermark is the watermark composited onto the image after resizing.
+	// If nil, no watermark is applied.
+	Watermark *Watermark
+
+	// Effort is the CPU effort the WebP, AVIF and GIF encoders spend on
+	// compression, from 0 to 9. It's capped to the range supported by each
+	// encoder.
+	Effort uint
+
+	// Lossless enables lossless compression for formats that support it.
+	Lossless bool
+
+	// MaxBytes is the maximum size of the output in bytes. If greater than
+	// zero, the highest quality up to Quality that produces a small enough
+	// output is used.
+	MaxBytes int
+
+	// TargetSSIM is the minimum structural similarity between the unencoded
+	// and encoded image, from 0 to 1. If greater than zero, the lowest quality
+	// meeting the target is used and Quality is ignored.
+	TargetSSIM float64
+
+	// NearLossless enables near-lossless compression for WebP images, using
+	// Quality to control the amount of preprocessing.
+	NearLossless bool
+
+	// Colorspace is the color space the image is converted to before any
+	// resizing. If empty, the image is left in its own color space, unless
+	// OptimizeICCProfile is set.
+	Colorspace Colorspace
+
+	// Keep lists the kinds of metadata kept in the image when StripMetadata
+	// is false. If empty, all metadata is kept.
+	Keep []Metadata
+
+	// Progressive enables progressive encoding for JPEG images. Interlaced
+	// only applies to PNG images.
+	Progressive bool
+
+	// Subsampling is the chroma subsampling of JPEG images. If empty, it's
+	// chosen by libvips based on the quality. It's ignored for other output
+	// formats.
+	Subsampling Subsampling
+
+	// Palette enables lossy quantization of PNG images to an 8-bit palette,
+	// using Quality to control the quantization.
+	Palette bool
+
+	// Colors is the number of colors in the PNG palette: 2, 4, 16 or 256. If
+	// zero, 256 is used. See ValidColors.
+	Colors uint
+
+	// Dither is the amount of error diffusion dithering applied when
+	// quantizing a PNG image, from 0 to 1.
+	Dither float64
+}
+
+// Result represents the outcome of processing an image.
+type Result struct {
+	// Format is the format of the encoded image.
+	Format Format
+
+	// Data is the encoded image.
+	Data []byte
+
+	// Quality is the quality the image was encoded with.
+	Quality uint
+
+	// SSIM is the structural similarity between the unencoded and encoded
+	// image. It's only calculated when Options.TargetSSIM is set.
+	SSIM float64
+
+	// Width is the width of the encoded image.
+	Width int
+
+	// Height is the height of the encoded image. For animated images, it's
+	// the height of a single frame.
+	Height int
+}
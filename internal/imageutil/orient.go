@@ -0,0 +1,93 @@
+package imageutil
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// Flip represents the direction an image is mirrored in.
+type Flip string
+
+// List of flip directions supported by the service.
+const (
+	// FlipHorizontal mirrors the image from left to right.
+	FlipHorizontal Flip = "h"
+
+	// FlipVertical mirrors the image from top to bottom.
+	FlipVertical Flip = "v"
+)
+
+// ParseFlip parses a flip direction, ignoring case. The "horizontal" and
+// "vertical" aliases are accepted as well.
+func ParseFlip(name string) (Flip, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "h", "horizontal":
+		return FlipHorizontal, nil
+	case "v", "vertical":
+		return FlipVertical, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedFlip, name)
+	}
+}
+
+// angles maps the supported rotations, in degrees, to libvips angles.
+var angles = map[uint]vips.Angle{
+	90:  vips.Angle90,
+	180: vips.Angle180,
+	270: vips.Angle270,
+}
+
+// ValidRotation reports whether degrees is a rotation supported by the
+// service, i.e. 0, 90, 180 or 270 degrees.
+func ValidRotation(degrees uint) bool {
+	return degrees%90 == 0 && degrees < 360
+}
+
+// orient rotates the image upright based on its EXIF orientation, if
+// opts.AutoOrient is set, and then applies opts.Rotate and opts.Flip.
+func (i *Image) orient(opts *Options) error {
+	if opts.AutoOrient {
+		if err := i.reference.AutoRotate(); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if opts.Rotate != 0 {
+		angle, ok := angles[opts.Rotate]
+		if !ok {
+			return fmt.Errorf("%w: %d", ErrUnsupportedRotation, opts.Rotate)
+		}
+
+		// libvips keeps the frames of animated images in order when rotating
+		// them by 90 or 270 degrees, but not by 180.
+		rotate := func(frame *vips.ImageRef) error {
+			return frame.Rotate(angle)
+		}
+
+		if angle == vips.Angle180 {
+			if err := i.eachFrame(rotate); err != nil {
+				return fmt.Errorf("%w", err)
+			}
+		} else if err := rotate(i.reference); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	switch opts.Flip {
+	case FlipHorizontal:
+		if err := i.reference.Flip(vips.DirectionHorizontal); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	case FlipVertical:
+		err := i.eachFrame(func(frame *vips.ImageRef) error {
+			return frame.Flip(vips.DirectionVertical)
+		})
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	return nil
+}
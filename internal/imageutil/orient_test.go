@@ -0,0 +1,120 @@
+package imageutil_test
+
+import (
+	"errors"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
+)
+
+func TestParseFlip(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		give    string
+		want    imageutil.Flip
+		wantErr error
+	}{
+		{
+			name: "horizontal",
+			give: "h",
+			want: imageutil.FlipHorizontal,
+		},
+		{
+			name: "vertical",
+			give: "v",
+			want: imageutil.FlipVertical,
+		},
+		{
+			name: "horizontal alias",
+			give: "Horizontal",
+			want: imageutil.FlipHorizontal,
+		},
+		{
+			name: "vertical alias with spaces",
+			give: " vertical ",
+			want: imageutil.FlipVertical,
+		},
+		{
+			name:    "unsupported direction",
+			give:    "diagonal",
+			wantErr: imageutil.ErrUnsupportedFlip,
+		},
+		{
+			name:    "empty direction",
+			give:    "",
+			wantErr: imageutil.ErrUnsupportedFlip,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := imageutil.ParseFlip(tt.give)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseFlip() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseFlip() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidRotation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		degrees uint
+		want    bool
+	}{
+		{
+			name:    "no rotation",
+			degrees: 0,
+			want:    true,
+		},
+		{
+			name:    "quarter turn",
+			degrees: 90,
+			want:    true,
+		},
+		{
+			name:    "half turn",
+			degrees: 180,
+			want:    true,
+		},
+		{
+			name:    "three quarter turn",
+			degrees: 270,
+			want:    true,
+		},
+		{
+			name:    "full turn",
+			degrees: 360,
+			want:    false,
+		},
+		{
+			name:    "not a multiple of 90",
+			degrees: 45,
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := imageutil.ValidRotation(tt.degrees); got != tt.want {
+				t.Errorf("ValidRotation(%d) = %v, want %v", tt.degrees, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package imageutil
+
+// minDither is the smallest amount of dithering sent to libvips. govips only
+// sets the dither option when it's not zero, so a dither of zero would fall
+// back to the libvips default of full dithering.
+const minDither float64 = 1e-6
+
+// paletteBitdepths is the list of bit depths a PNG palette can have, from
+// smallest to largest.
+var paletteBitdepths = []int{1, 2, 4, 8}
+
+// ValidColors reports whether colors is a palette size supported by PNG, i.e.
+// 2, 4, 16 or 256 colors. libvips takes the bit depth of the palette rather
+// than a number of colors, so no other size can be honored.
+func ValidColors(colors uint) bool {
+	for _, depth := range paletteBitdepths {
+		if colors == 1<<depth {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PaletteBitdepth returns the PNG bit depth of a palette with the given
+// number of colors, or zero if palette quantization is disabled. Palettes
+// with zero or an unsupported number of colors use the largest bit depth.
+func PaletteBitdepth(palette bool, colors uint) int {
+	if !palette {
+		return 0
+	}
+
+	for _, depth := range paletteBitdepths {
+		if colors == 1<<depth {
+			return depth
+		}
+	}
+
+	return paletteBitdepths[len(paletteBitdepths)-1]
+}
@@ -0,0 +1,69 @@
+package imageutil_test
+
+import (
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
+)
+
+func TestValidColors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give uint
+		want bool
+	}{
+		{name: "2 colors", give: 2, want: true},
+		{name: "4 colors", give: 4, want: true},
+		{name: "16 colors", give: 16, want: true},
+		{name: "256 colors", give: 256, want: true},
+		{name: "zero", give: 0, want: false},
+		{name: "8 colors", give: 8, want: false},
+		{name: "64 colors", give: 64, want: false},
+		{name: "128 colors", give: 128, want: false},
+		{name: "512 colors", give: 512, want: false},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := imageutil.ValidColors(tt.give); got != tt.want {
+				t.Errorf("ValidColors(%d) = %t, want %t", tt.give, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPaletteBitdepth(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		palette bool
+		colors  uint
+		want    int
+	}{
+		{name: "palette disabled", palette: false, colors: 16, want: 0},
+		{name: "2 colors", palette: true, colors: 2, want: 1},
+		{name: "4 colors", palette: true, colors: 4, want: 2},
+		{name: "16 colors", palette: true, colors: 16, want: 4},
+		{name: "256 colors", palette: true, colors: 256, want: 8},
+		{name: "zero colors", palette: true, colors: 0, want: 8},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := imageutil.PaletteBitdepth(tt.palette, tt.colors); got != tt.want {
+				t.Errorf("PaletteBitdepth(%t, %d) = %d, want %d", tt.palette, tt.colors, got, tt.want)
+			}
+		})
+	}
+}
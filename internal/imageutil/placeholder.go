@@ -0,0 +1,149 @@
+package imageutil
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+const (
+	// placeholderSampleSize is the size, in pixels, of the longest side of the
+	// copy of the image the BlurHash and dominant color are computed from.
+	placeholderSampleSize int = 32
+
+	// placeholderSize is the size, in pixels, of the longest side of the
+	// low-quality placeholder image.
+	placeholderSize int = 16
+
+	// placeholderBlur is the Gaussian blur sigma applied to the low-quality
+	// placeholder image.
+	placeholderBlur float64 = 1
+
+	// placeholderQuality is the WebP quality the low-quality placeholder image
+	// is encoded with.
+	placeholderQuality int = 20
+
+	// blurHashComponents is the number of BlurHash components along the
+	// longest side of the image. The shortest side has one less.
+	blurHashComponents int = 4
+)
+
+// Placeholder holds the previews of an image clients show while the image
+// itself loads.
+type Placeholder struct {
+	// DataURI is a tiny blurred copy of the image encoded as a WebP data URI.
+	DataURI string
+
+	// BlurHash is the BlurHash string of the image.
+	BlurHash string
+
+	// Color is the dominant color of the image.
+	Color Color
+
+	// Width is the width of the image, after it's oriented.
+	Width int
+
+	// Height is the height of the image, after it's oriented. For animated
+	// images, it's the height of a single frame.
+	Height int
+}
+
+// Placeholder returns the previews of the image. Only the first frame of
+// animated images is used, and the image is modified in the process.
+func (i *Image) Placeholder() (*Placeholder, error) {
+	if i.Animated() {
+		if err := i.firstFrame(); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	if err := i.reference.AutoRotate(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if err := i.reference.ToColorSpace(vips.InterpretationSRGB); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if err := i.to8Bit(); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	placeholder := &Placeholder{
+		Width:  i.reference.Width(),
+		Height: i.reference.Height(),
+	}
+
+	if err := i.reference.Thumbnail(placeholderSampleSize, placeholderSampleSize, vips.InterestingNone); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	pixels, err := i.pixels()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	var (
+		width       = i.reference.Width()
+		height      = i.reference.Height()
+		xComponents = blurHashComponents
+		yComponents = blurHashComponents
+	)
+
+	if width >= height {
+		yComponents--
+	} else {
+		xComponents--
+	}
+
+	placeholder.BlurHash, err = BlurHash(pixels, width, height, xComponents, yComponents)
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	placeholder.Color = DominantColor(pixels)
+
+	if err = i.reference.Thumbnail(placeholderSize, placeholderSize, vips.InterestingNone); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if err = i.reference.GaussianBlur(placeholderBlur); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	data, _, err := i.reference.ExportWebp(&vips.WebpExportParams{
+		StripMetadata: true,
+		Quality:       placeholderQuality,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrExportImage, err)
+	}
+
+	placeholder.DataURI = "data:" + FormatWebP.MIMEType() + ";base64," + base64.StdEncoding.EncodeToString(data)
+
+	return placeholder, nil
+}
+
+// pixels returns the pixels of an 8-bit sRGB image in RGB order, row by row.
+// Transparent images are flattened onto white first.
+func (i *Image) pixels() ([]byte, error) {
+	reference, err := i.reference.Copy()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	defer reference.Close()
+
+	if reference.HasAlpha() {
+		if err = reference.Flatten(&vips.Color{R: 255, G: 255, B: 255}); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	pixels, err := reference.ToBytes()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return pixels, nil
+}
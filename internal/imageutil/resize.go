@@ -0,0 +1,262 @@
+package imageutil
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// Fit represents how an image is resized to fit the requested dimensions.
+type Fit string
+
+// List of fit modes supported by the service.
+const (
+	// FitCover resizes the image to cover both dimensions, cropping the
+	// excess.
+	FitCover Fit = "cover"
+
+	// FitContain resizes the image to fit within both dimensions and
+	// letterboxes it with a background color to the exact dimensions.
+	FitContain Fit = "contain"
+
+	// FitFill resizes the image to the exact dimensions, ignoring its aspect
+	// ratio.
+	FitFill Fit = "fill"
+
+	// FitInside resizes the image to fit within both dimensions.
+	FitInside Fit = "inside"
+
+	// FitOutside resizes the image so both dimensions are greater than or
+	// equal to the requested ones.
+	FitOutside Fit = "outside"
+)
+
+// Fits is the list of fit modes supported by the service.
+var Fits = []Fit{
+	FitCover,
+	FitContain,
+	FitFill,
+	FitInside,
+	FitOutside,
+}
+
+// ParseFit parses the name of a fit mode, ignoring case.
+func ParseFit(name string) (Fit, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	for _, fit := range Fits {
+		if Fit(name) == fit {
+			return fit, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedFit, name)
+}
+
+// resize scales the image down to fit the given dimensions using opts.Fit. A
+// zero dimension is calculated from the other one while keeping the aspect
+// ratio, and the image itself is never enlarged. If focus is not nil, it's
+// used instead of opts.Gravity when cropping.
+//
+// Animated images are resized frame by frame.
+func (i *Image) resize(opts *Options, focus *point) error {
+	var (
+		width          = opts.Width
+		height         = opts.Height
+		originalWidth  = i.reference.Width()
+		originalHeight = i.reference.PageHeight()
+		aspectRatio    = float64(originalWidth) / float64(originalHeight)
+	)
+
+	if width == 0 {
+		width = uint(math.Round(float64(height) * aspectRatio))
+	} else if height == 0 {
+		height = uint(math.Round(float64(width) / aspectRatio))
+	}
+
+	var err error
+
+	switch opts.Fit {
+	case FitContain:
+		err = i.contain(int(width), int(height), opts.Gravity, opts.Background)
+	case FitFill:
+		err = i.thumbnail(
+			min(int(width), originalWidth),
+			min(int(height), originalHeight),
+			vips.InterestingNone,
+			vips.SizeForce,
+		)
+	case FitInside:
+		err = i.thumbnail(int(width), int(height), vips.InterestingNone, vips.SizeDown)
+	case FitOutside:
+		scale := math.Min(1, math.Max(
+			float64(width)/float64(originalWidth),
+			float64(height)/float64(originalHeight),
+		))
+
+		err = i.thumbnail(
+			int(math.Round(float64(originalWidth)*scale)),
+			int(math.Round(float64(originalHeight)*scale)),
+			vips.InterestingNone,
+			vips.SizeDown,
+		)
+	default:
+		err = i.cover(min(int(width), originalWidth), min(int(height), originalHeight), opts.Gravity, focus)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// thumbnail resizes the image to the given dimensions like libvips'
+// thumbnail operation, which doesn't understand animated images. Those are
+// resized with scale and, if crop isn't vips.InterestingNone, cropped around
+// the center of every frame instead.
+func (i *Image) thumbnail(width, height int, crop vips.Interesting, size vips.Size) error {
+	if !i.Animated() {
+		return i.reference.ThumbnailWithSize(width, height, crop, size)
+	}
+
+	var (
+		frameWidth  = float64(i.reference.Width())
+		frameHeight = float64(i.reference.PageHeight())
+		hscale      = math.Min(float64(width)/frameWidth, float64(height)/frameHeight)
+		vscale      = hscale
+	)
+
+	switch {
+	case size == vips.SizeForce:
+		hscale = float64(width) / frameWidth
+		vscale = float64(height) / frameHeight
+	case crop != vips.InterestingNone:
+		hscale = math.Max(float64(width)/frameWidth, float64(height)/frameHeight)
+		vscale = hscale
+	}
+
+	if size == vips.SizeDown {
+		hscale = math.Min(hscale, 1)
+		vscale = math.Min(vscale, 1)
+	}
+
+	if err := i.scale(hscale, vscale); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if crop == vips.InterestingNone {
+		return nil
+	}
+
+	width = min(width, i.reference.Width())
+	height = min(height, i.reference.PageHeight())
+
+	left, top := GravityCentre.offset(i.reference.Width(), i.reference.PageHeight(), width, height)
+
+	if err := i.reference.ExtractArea(left, top, width, height); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// scale resizes the image by the given horizontal and vertical factors. For
+// animated images, the vertical factor is adjusted so every frame ends up
+// with the same whole number of rows.
+func (i *Image) scale(hscale, vscale float64) error {
+	var rows int
+
+	if i.Animated() {
+		frameHeight := float64(i.reference.PageHeight())
+		rows = int(math.Max(1, math.Round(frameHeight*vscale)))
+		vscale = float64(rows) / frameHeight
+	}
+
+	if err := i.reference.ResizeWithVScale(hscale, vscale, vips.KernelAuto); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	// govips truncates the page height it sets after resizing, which can
+	// leave it a row short, and libvips ignores page heights that don't
+	// divide the image, merging the frames into one.
+	if rows > 0 {
+		if err := i.reference.SetPageHeight(rows); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	return nil
+}
+
+// cover scales the image down to cover the given dimensions and crops the
+// excess, keeping the region centered on focus or, if it's nil, the region
+// selected by gravity.
+func (i *Image) cover(width, height int, gravity Gravity, focus *point) error {
+	if focus == nil {
+		switch gravity {
+		case "", GravityCentre:
+			return i.thumbnail(width, height, vips.InterestingCentre, vips.SizeBoth)
+		case GravityAttention:
+			return i.thumbnail(width, height, vips.InterestingAttention, vips.SizeBoth)
+		case GravityEntropy:
+			return i.thumbnail(width, height, vips.InterestingEntropy, vips.SizeBoth)
+		}
+	}
+
+	scale := math.Max(
+		float64(width)/float64(i.reference.Width()),
+		float64(height)/float64(i.reference.PageHeight()),
+	)
+
+	if err := i.scale(scale, scale); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	// Rounding may leave the resized image a pixel short of the target.
+	width = min(width, i.reference.Width())
+	height = min(height, i.reference.PageHeight())
+
+	left, top := gravity.offset(i.reference.Width(), i.reference.PageHeight(), width, height)
+
+	if focus != nil {
+		left = clamp(int(math.Round(float64(focus.x)*scale))-width/2, 0, i.reference.Width()-width)
+		top = clamp(int(math.Round(float64(focus.y)*scale))-height/2, 0, i.reference.PageHeight()-height)
+	}
+
+	if err := i.reference.ExtractArea(left, top, width, height); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// contain scales the image down to fit within the given dimensions and
+// places it on a canvas of that exact size filled with background, at the
+// position selected by gravity.
+func (i *Image) contain(width, height int, gravity Gravity, background Color) error {
+	if err := i.thumbnail(width, height, vips.InterestingNone, vips.SizeDown); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if background.A < 255 && !i.reference.HasAlpha() {
+		if err := i.reference.AddAlpha(); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	left, top := gravity.offset(width, height, i.reference.Width(), i.reference.PageHeight())
+
+	if err := i.reference.EmbedBackgroundRGBA(left, top, width, height, background.vips()); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+// clamp returns value limited to the range [low, high].
+func clamp(value, low, high int) int {
+	return max(low, min(value, high))
+}
@@ -0,0 +1,106 @@
+package imageutil_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
+)
+
+// animatedGIF returns an animated GIF image with the given number of frames
+// of the given size, each filled with a different color.
+func animatedGIF(t *testing.T, width, height, frames int) []byte {
+	t.Helper()
+
+	var (
+		palette   = color.Palette{color.Black, color.White, color.RGBA{R: 255, A: 255}}
+		animation = &gif.GIF{}
+	)
+
+	for frame := 0; frame < frames; frame++ {
+		paletted := image.NewPaletted(image.Rect(0, 0, width, height), palette)
+
+		for i := range paletted.Pix {
+			paletted.Pix[i] = uint8(frame % len(palette))
+		}
+
+		animation.Image = append(animation.Image, paletted)
+		animation.Delay = append(animation.Delay, 10)
+	}
+
+	var buffer bytes.Buffer
+
+	if err := gif.EncodeAll(&buffer, animation); err != nil {
+		t.Fatalf("EncodeAll() error = %v", err)
+	}
+
+	return buffer.Bytes()
+}
+
+func TestImage_Process_AnimatedResize(t *testing.T) {
+	t.Parallel()
+
+	// A frame height of 22 scaled to 15 rows is truncated to 14 by govips,
+	// which doesn't divide the resized image.
+	tests := []struct {
+		name string
+		fit  imageutil.Fit
+	}{
+		{
+			name: "cover",
+			fit:  imageutil.FitCover,
+		},
+		{
+			name: "inside",
+			fit:  imageutil.FitInside,
+		},
+		{
+			name: "outside",
+			fit:  imageutil.FitOutside,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			img, err := imageutil.Open(bytes.NewReader(animatedGIF(t, 20, 22, 3)))
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer img.Close()
+
+			result, err := img.Process(&imageutil.Options{
+				Format:  imageutil.FormatGIF,
+				Quality: 75,
+				Height:  15,
+				Fit:     tt.fit,
+			})
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+
+			if result.Height != 15 {
+				t.Errorf("Process() Height = %d, want 15", result.Height)
+			}
+
+			decoded, err := gif.DecodeAll(bytes.NewReader(result.Data))
+			if err != nil {
+				t.Fatalf("DecodeAll() error = %v", err)
+			}
+
+			if len(decoded.Image) != 3 {
+				t.Fatalf("Process() frames = %d, want 3", len(decoded.Image))
+			}
+
+			if decoded.Config.Height != 15 {
+				t.Errorf("Process() frame height = %d, want 15", decoded.Config.Height)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package imageutil
+
+import (
+	"fmt"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// ssimWindow is the size of the square windows SSIM is computed over.
+const ssimWindow int = 8
+
+// Stabilization constants from the original SSIM paper for 8-bit images.
+const (
+	ssimC1 float64 = (0.01 * 255) * (0.01 * 255)
+	ssimC2 float64 = (0.03 * 255) * (0.03 * 255)
+)
+
+// SSIM computes the mean structural similarity index between two 8-bit
+// grayscale images of the given dimensions. The images are compared over
+// non-overlapping 8x8 windows, and the result ranges from -1 to 1, where 1
+// means the images are identical.
+//
+// It returns zero if the length of a or b doesn't match the dimensions.
+func SSIM(a, b []byte, width, height int) float64 {
+	if width <= 0 || height <= 0 || len(a) != width*height || len(b) != width*height {
+		return 0
+	}
+
+	var (
+		windowWidth  = min(ssimWindow, width)
+		windowHeight = min(ssimWindow, height)
+		total        float64
+		windows      int
+	)
+
+	for top := 0; top+windowHeight <= height; top += windowHeight {
+		for left := 0; left+windowWidth <= width; left += windowWidth {
+			total += ssimWindowAt(a, b, width, left, top, windowWidth, windowHeight)
+			windows++
+		}
+	}
+
+	return total / float64(windows)
+}
+
+// ssimWindowAt computes the structural similarity index of a single window.
+func ssimWindowAt(a, b []byte, stride, left, top, width, height int) float64 {
+	var (
+		n                   = float64(width * height)
+		sumA, sumB          float64
+		sumAA, sumBB, sumAB float64
+	)
+
+	for y := top; y < top+height; y++ {
+		for x := left; x < left+width; x++ {
+			var (
+				pa = float64(a[y*stride+x])
+				pb = float64(b[y*stride+x])
+			)
+
+			sumA += pa
+			sumB += pb
+			sumAA += pa * pa
+			sumBB += pb * pb
+			sumAB += pa * pb
+		}
+	}
+
+	var (
+		meanA      = sumA / n
+		meanB      = sumB / n
+		varianceA  = sumAA/n - meanA*meanA
+		varianceB  = sumBB/n - meanB*meanB
+		covariance = sumAB/n - meanA*meanB
+	)
+
+	return ((2*meanA*meanB + ssimC1) * (2*covariance + ssimC2)) /
+		((meanA*meanA + meanB*meanB + ssimC1) * (varianceA + varianceB + ssimC2))
+}
+
+// luminance returns the 8-bit luminance of an image as raw bytes, one per
+// pixel.
+func luminance(reference *vips.ImageRef) ([]byte, error) {
+	image, err := reference.Copy()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+	defer image.Close()
+
+	if err = image.ToColorSpace(vips.InterpretationBW); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	if image.Bands() > 1 {
+		if err = image.ExtractBand(0, 1); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	if image.BandFormat() == vips.BandFormatUshort {
+		if err = image.Linear1(1.0/257, 0); err != nil {
+			return nil, fmt.Errorf("%w", err)
+		}
+	}
+
+	if err = image.Cast(vips.BandFormatUchar); err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	data, err := image.ToBytes()
+	if err != nil {
+		return nil, fmt.Errorf("%w", err)
+	}
+
+	return data, nil
+}
+
+// similarity decodes an image encoded in the given format and returns its
+// structural similarity to the given luminance of the unencoded image.
+func similarity(encoded []byte, format Format, reference []byte, width, height int) (float64, error) {
+	image, err := load(encoded, format)
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+	defer image.Close()
+
+	decoded, err := luminance(image)
+	if err != nil {
+		return 0, fmt.Errorf("%w", err)
+	}
+
+	return SSIM(reference, decoded, width, height), nil
+}
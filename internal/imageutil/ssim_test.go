@@ -0,0 +1,77 @@
+package imageutil_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
+)
+
+func TestSSIM(t *testing.T) {
+	t.Parallel()
+
+	gradient := make([]byte, 16*16)
+	for i := range gradient {
+		gradient[i] = byte(i)
+	}
+
+	inverted := make([]byte, len(gradient))
+	for i, v := range gradient {
+		inverted[i] = 255 - v
+	}
+
+	tests := []struct {
+		name   string
+		a      []byte
+		b      []byte
+		width  int
+		height int
+		want   func(float64) bool
+	}{
+		{
+			name:   "identical images",
+			a:      gradient,
+			b:      gradient,
+			width:  16,
+			height: 16,
+			want:   func(score float64) bool { return math.Abs(score-1) < 1e-9 },
+		},
+		{
+			name:   "inverted images",
+			a:      gradient,
+			b:      inverted,
+			width:  16,
+			height: 16,
+			want:   func(score float64) bool { return score < 0.5 },
+		},
+		{
+			name:   "image smaller than the window",
+			a:      bytes.Repeat([]byte{128}, 4),
+			b:      bytes.Repeat([]byte{128}, 4),
+			width:  2,
+			height: 2,
+			want:   func(score float64) bool { return math.Abs(score-1) < 1e-9 },
+		},
+		{
+			name:   "mismatched dimensions",
+			a:      gradient,
+			b:      gradient[:10],
+			width:  16,
+			height: 16,
+			want:   func(score float64) bool { return score == 0 },
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := imageutil.SSIM(tt.a, tt.b, tt.width, tt.height); !tt.want(got) {
+				t.Errorf("SSIM() = %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package imageutil
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// Subsampling represents the chroma subsampling of a JPEG image.
+type Subsampling string
+
+// List of chroma subsampling modes supported by the service. libvips cannot
+// encode 4:2:2 JPEG images, so it's not supported.
+const (
+	// Subsampling444 keeps the full chroma resolution, which avoids color
+	// bleeding around sharp edges such as colored text.
+	Subsampling444 Subsampling = "444"
+
+	// Subsampling420 halves the chroma resolution in both directions,
+	// producing smaller images.
+	Subsampling420 Subsampling = "420"
+)
+
+// ParseSubsampling parses a chroma subsampling mode. The "4:4:4" and "4:2:0"
+// notations are accepted as well.
+func ParseSubsampling(name string) (Subsampling, error) {
+	switch strings.ReplaceAll(strings.TrimSpace(name), ":", "") {
+	case string(Subsampling444):
+		return Subsampling444, nil
+	case string(Subsampling420):
+		return Subsampling420, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedSubsampling, name)
+	}
+}
+
+// vips returns the libvips subsample mode for the subsampling. If empty,
+// libvips picks 4:4:4 for qualities of 90 and above and 4:2:0 otherwise.
+func (s Subsampling) vips() vips.SubsampleMode {
+	switch s {
+	case Subsampling444:
+		return vips.VipsForeignSubsampleOff
+	case Subsampling420:
+		return vips.VipsForeignSubsampleOn
+	default:
+		return vips.VipsForeignSubsampleAuto
+	}
+}
@@ -0,0 +1,52 @@
+package imageutil_test
+
+import (
+	"errors"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
+)
+
+func TestParseSubsampling(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		give    string
+		want    imageutil.Subsampling
+		wantErr error
+	}{
+		{
+			name: "4:4:4",
+			give: "444",
+			want: imageutil.Subsampling444,
+		},
+		{
+			name: "4:2:0 with colons",
+			give: "4:2:0",
+			want: imageutil.Subsampling420,
+		},
+		{
+			name:    "4:2:2",
+			give:    "422",
+			wantErr: imageutil.ErrUnsupportedSubsampling,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := imageutil.ParseSubsampling(tt.give)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParseSubsampling() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("ParseSubsampling() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
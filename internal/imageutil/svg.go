@@ -0,0 +1,406 @@
+package imageutil
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// svgDensity is the density, in dots per inch, SVG images are rendered at by
+// default.
+const svgDensity float64 = 72
+
+// unsafeElements is the list of SVG elements removed from documents together
+// with their content, either because they can run code or embed arbitrary
+// markup, or because they only hold editor metadata.
+var unsafeElements = map[string]bool{
+	"script":        true,
+	"foreignobject": true,
+	"metadata":      true,
+}
+
+// animationElements is the list of SVG elements that set the attribute named
+// by their attributeName attribute to the values of animationValues.
+var animationElements = map[string]bool{
+	"set":     true,
+	"animate": true,
+}
+
+// animationValues is the list of attributes of animation elements holding
+// the values the animated attribute is set to.
+var animationValues = map[string]bool{
+	"to":     true,
+	"from":   true,
+	"by":     true,
+	"values": true,
+}
+
+// editorNamespaces is the list of namespace URI prefixes used by vector
+// editors to store their own metadata in SVG documents.
+var editorNamespaces = []string{
+	"http://www.inkscape.org/namespaces/inkscape",
+	"http://sodipodi.sourceforge.net/DTD/sodipodi-0.dtd",
+	"http://www.bohemiancoding.com/sketch/ns",
+	"http://ns.adobe.com/",
+	"http://purl.org/dc/elements/1.1/",
+	"http://creativecommons.org/ns#",
+	"http://www.w3.org/1999/02/22-rdf-syntax-ns#",
+}
+
+// SanitizeSVG removes scripts, event handlers, external references, comments
+// and editor metadata from an SVG document and collapses its whitespace.
+//
+// Document type declarations are dropped, and custom entities and end elements
+// that don't match their start elements are rejected, so the result is safe
+// to serve to browsers and to hand to librsvg.
+func SanitizeSVG(data []byte) ([]byte, error) {
+	var (
+		decoder = xml.NewDecoder(bytes.NewReader(data))
+		editors = make(map[string]bool)
+		buffer  bytes.Buffer
+		css     strings.Builder
+		stack   []xml.Name
+		root    bool
+		open    bool
+		style   int
+		skip    int
+	)
+
+	for {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidSVG, err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name)
+
+			// Removed elements are dropped with their content, and so are
+			// the child elements of style sheets, whose text is kept and
+			// checked together with the rest of the style sheet.
+			if skip > 0 || style > 0 {
+				continue
+			}
+
+			if !root {
+				if !strings.EqualFold(t.Name.Local, "svg") {
+					return nil, fmt.Errorf("%w: root element is %q", ErrInvalidSVG, t.Name.Local)
+				}
+
+				root = true
+			}
+
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" && editorNamespace(attr.Value) {
+					editors[attr.Name.Local] = true
+				}
+			}
+
+			if unsafeElements[strings.ToLower(t.Name.Local)] || editors[t.Name.Space] {
+				skip = len(stack)
+
+				continue
+			}
+
+			if open {
+				buffer.WriteByte('>')
+			}
+
+			buffer.WriteByte('<')
+			buffer.WriteString(qualifiedName(t.Name))
+
+			animated := animatedAttribute(t)
+
+			for _, attr := range t.Attr {
+				if !safeAttribute(attr, editors, animated) {
+					continue
+				}
+
+				buffer.WriteByte(' ')
+				buffer.WriteString(qualifiedName(attr.Name))
+				buffer.WriteString(`="`)
+				_ = xml.EscapeText(&buffer, []byte(attr.Value))
+				buffer.WriteByte('"')
+			}
+
+			open = true
+
+			if strings.EqualFold(t.Name.Local, "style") {
+				style = len(stack)
+				css.Reset()
+			}
+		case xml.EndElement:
+			// RawToken does not match end elements to start elements, so a
+			// stray end element could otherwise close a removed element early
+			// and let its content through.
+			if len(stack) == 0 || stack[len(stack)-1] != t.Name {
+				return nil, fmt.Errorf("%w: unexpected end element %q", ErrInvalidSVG, qualifiedName(t.Name))
+			}
+
+			depth := len(stack)
+			stack = stack[:depth-1]
+
+			if skip > 0 {
+				if depth == skip {
+					skip = 0
+				}
+
+				continue
+			}
+
+			if style > 0 && depth > style {
+				continue
+			}
+
+			if style == depth {
+				text := collapseSpace(css.String())
+				if text != "" && !strings.Contains(normalizeCSS(text), "@import") && !externalURL(text) {
+					buffer.WriteByte('>')
+					_ = xml.EscapeText(&buffer, []byte(text))
+					open = false
+				}
+
+				css.Reset()
+
+				style = 0
+			}
+
+			if open {
+				buffer.WriteString("/>")
+			} else {
+				buffer.WriteString("</" + qualifiedName(t.Name) + ">")
+			}
+
+			open = false
+		case xml.CharData:
+			if skip > 0 {
+				continue
+			}
+
+			// Style sheets are checked as a whole once the element ends, since
+			// comments and child elements can split them into several tokens.
+			if style > 0 {
+				css.Write(t)
+
+				continue
+			}
+
+			text := collapseSpace(string(t))
+			if text == "" {
+				continue
+			}
+
+			if open {
+				buffer.WriteByte('>')
+				open = false
+			}
+
+			_ = xml.EscapeText(&buffer, []byte(text))
+		}
+	}
+
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%w: unclosed element %q", ErrInvalidSVG, qualifiedName(stack[len(stack)-1]))
+	}
+
+	if !root {
+		return nil, fmt.Errorf("%w: missing root element", ErrInvalidSVG)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// collapseSpace collapses runs of whitespace in text to a single space.
+// Leading and trailing whitespace is kept as a single space, and text made
+// of whitespace only is collapsed to an empty string.
+func collapseSpace(text string) string {
+	collapsed := strings.Join(strings.Fields(text), " ")
+	if collapsed == "" {
+		return ""
+	}
+
+	if unicode.IsSpace(rune(text[0])) {
+		collapsed = " " + collapsed
+	}
+
+	if unicode.IsSpace(rune(text[len(text)-1])) {
+		collapsed += " "
+	}
+
+	return collapsed
+}
+
+// animatedAttribute returns the lowercase local name of the attribute an
+// animation element changes, or an empty string for other elements.
+func animatedAttribute(element xml.StartElement) string {
+	if !animationElements[strings.ToLower(element.Name.Local)] {
+		return ""
+	}
+
+	for _, attr := range element.Attr {
+		if attr.Name.Space == "" && attr.Name.Local == "attributeName" {
+			name := strings.ToLower(stripControl(attr.Value))
+
+			return name[strings.LastIndex(name, ":")+1:]
+		}
+	}
+
+	return ""
+}
+
+// safeAttribute reports whether an attribute can be kept in a sanitized SVG
+// document. For animation elements, animated is the name of the attribute
+// they change, and the values they set it to must be safe for it as well.
+func safeAttribute(attr xml.Attr, editors map[string]bool, animated string) bool {
+	var (
+		name  = strings.ToLower(attr.Name.Local)
+		value = strings.ToLower(stripControl(attr.Value))
+	)
+
+	if animated != "" && attr.Name.Space == "" && animationValues[name] {
+		for _, animatedValue := range strings.Split(attr.Value, ";") {
+			if !safeAttribute(xml.Attr{Name: xml.Name{Local: animated}, Value: animatedValue}, editors, "") {
+				return false
+			}
+		}
+	}
+
+	switch {
+	case attr.Name.Space == "xmlns" && editors[attr.Name.Local]:
+		return false
+	case editors[attr.Name.Space]:
+		return false
+	case strings.HasPrefix(name, "on"):
+		return false
+	case name == "href" && !strings.HasPrefix(value, "#") && !rasterDataURI(value):
+		return false
+	case strings.Contains(value, "javascript:"):
+		return false
+	case externalURL(attr.Value):
+		return false
+	}
+
+	return true
+}
+
+// stripControl removes ASCII whitespace and control characters from value,
+// which browsers ignore in URLs, so "java\tscript:" is caught as well.
+func stripControl(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+
+		return r
+	}, value)
+}
+
+// editorNamespace reports whether a namespace URI belongs to a vector editor.
+func editorNamespace(uri string) bool {
+	for _, namespace := range editorNamespaces {
+		if strings.HasPrefix(uri, namespace) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// externalURL reports whether a CSS value references anything but a fragment
+// of the document or an embedded raster image through url().
+func externalURL(value string) bool {
+	value = normalizeCSS(value)
+
+	for {
+		index := strings.Index(value, "url(")
+		if index < 0 {
+			return false
+		}
+
+		value = value[index+len("url("):]
+
+		target := strings.TrimLeft(value, " \t\r\n'\"")
+		if !strings.HasPrefix(target, "#") && !rasterDataURI(target) {
+			return true
+		}
+	}
+}
+
+// normalizeCSS removes comments from CSS, decodes its escapes and lowercases
+// it, so references cannot be hidden from the checks above with something like
+// u\72l() or @\69mport.
+func normalizeCSS(css string) string {
+	var normalized strings.Builder
+
+	for i := 0; i < len(css); i++ {
+		switch {
+		case strings.HasPrefix(css[i:], "/*"):
+			end := strings.Index(css[i+2:], "*/")
+			if end < 0 {
+				return strings.ToLower(normalized.String())
+			}
+
+			i += end + 3
+		case css[i] == '\\' && i+1 < len(css):
+			i++
+
+			digits := 0
+			for digits < 6 && i+digits < len(css) && isHexDigit(css[i+digits]) {
+				digits++
+			}
+
+			if digits == 0 {
+				if css[i] != '\n' {
+					normalized.WriteByte(css[i])
+				}
+
+				continue
+			}
+
+			code, _ := strconv.ParseUint(css[i:i+digits], 16, 32)
+			normalized.WriteRune(rune(code))
+
+			i += digits - 1
+
+			if i+1 < len(css) && unicode.IsSpace(rune(css[i+1])) {
+				i++
+			}
+		default:
+			normalized.WriteByte(css[i])
+		}
+	}
+
+	return strings.ToLower(normalized.String())
+}
+
+// isHexDigit reports whether c is a hexadecimal digit.
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// rasterDataURI reports whether a lowercase URI is a data URI holding a
+// raster image. SVG data URIs are rejected since they may contain scripts
+// themselves.
+func rasterDataURI(uri string) bool {
+	return strings.HasPrefix(uri, "data:image/") && !strings.HasPrefix(uri, "data:image/svg")
+}
+
+// qualifiedName returns the name of an element or attribute as written in
+// the document.
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+
+	return name.Space + ":" + name.Local
+}
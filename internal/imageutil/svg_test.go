@@ -0,0 +1,122 @@
+package imageutil_test
+
+import (
+	"errors"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
+)
+
+func TestSanitizeSVG(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		give    string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "whitespace and comments",
+			give: "<?xml version=\"1.0\"?>\n<!-- icon -->\n<svg xmlns=\"http://www.w3.org/2000/svg\">\n  <path d=\"M0 0h10v10z\"></path>\n  <text>  Hello\n   world </text>\n</svg>\n",
+			want: `<svg xmlns="http://www.w3.org/2000/svg"><path d="M0 0h10v10z"/><text> Hello world </text></svg>`,
+		},
+		{
+			name: "scripts and event handlers",
+			give: `<svg onload="alert(1)"><script>alert(2)</script><rect width="1" onclick="alert(3)"/></svg>`,
+			want: `<svg><rect width="1"/></svg>`,
+		},
+		{
+			name: "external references",
+			give: `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><use href="#a"/><use xlink:href="https://example.com/a.svg#b"/><image href="javascript:alert(1)"/><rect fill="url(#g)" style="fill: url(https://example.com/x)"/></svg>`,
+			want: `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><use href="#a"/><use/><image/><rect fill="url(#g)"/></svg>`,
+		},
+		{
+			name: "style imports",
+			give: `<svg><style>@import url(https://example.com/a.css);</style></svg>`,
+			want: `<svg><style/></svg>`,
+		},
+		{
+			name: "style imports split by comments",
+			give: `<svg><style>@imp<!-- -->ort "https://example.com/a.css";</style></svg>`,
+			want: `<svg><style/></svg>`,
+		},
+		{
+			name: "style with escaped references",
+			give: `<svg><style>rect { fill: u\72l(https://example.com/x) } @\69mport "a.css";</style><rect style="fill: u\72l(https://example.com/x)"/></svg>`,
+			want: `<svg><style/><rect/></svg>`,
+		},
+		{
+			name: "style split by child elements",
+			give: `<svg xmlns="http://www.w3.org/2000/svg"><style><x/>@import url(https://evil.example/a.css); rect{fill:url(https://evil.example/t)}</style></svg>`,
+			want: `<svg xmlns="http://www.w3.org/2000/svg"><style/></svg>`,
+		},
+		{
+			name: "safe style with child elements",
+			give: `<svg><style>rect { <x>fill: </x>red }</style></svg>`,
+			want: `<svg><style>rect { fill: red }</style></svg>`,
+		},
+		{
+			name: "safe style",
+			give: "<svg><style>\n  rect { fill: url(#g) }\n  /* note */\n</style></svg>",
+			want: `<svg><style> rect { fill: url(#g) } /* note */ </style></svg>`,
+		},
+		{
+			name: "animated references",
+			give: `<svg><a><set attributeName="href" to="java&#9;script:alert(1)"/><set attributeName="xlink:href" to="https://example.com/"/><animate attributeName="href" values="#a;https://example.com/"/><set attributeName="href" to="#a"/><set attributeName="fill" to="red"/></a></svg>`,
+			want: `<svg><a><set attributeName="href"/><set attributeName="xlink:href"/><animate attributeName="href"/><set attributeName="href" to="#a"/><set attributeName="fill" to="red"/></a></svg>`,
+		},
+		{
+			name: "scripts hidden with control characters",
+			give: `<svg><a href=" java&#9;script:alert(1)"/><a href="&#13;javascript:alert(1)"/><rect fill="java&#10;script:alert(1)"/></svg>`,
+			want: `<svg><a/><a/><rect/></svg>`,
+		},
+		{
+			name: "editor metadata",
+			give: `<svg xmlns:inkscape="http://www.inkscape.org/namespaces/inkscape" xmlns:sodipodi="http://sodipodi.sourceforge.net/DTD/sodipodi-0.dtd" inkscape:version="1.0"><metadata><rdf:RDF/></metadata><sodipodi:namedview/><g inkscape:label="Layer"/></svg>`,
+			want: `<svg><g/></svg>`,
+		},
+		{
+			name:    "custom entities",
+			give:    `<!DOCTYPE svg [<!ENTITY a "b">]><svg>&a;</svg>`,
+			wantErr: imageutil.ErrInvalidSVG,
+		},
+		{
+			name:    "stray end element in removed element",
+			give:    `<svg><foreignObject></x><div xmlns="http://www.w3.org/1999/xhtml"><script>alert(1)</script></div></foreignObject></svg>`,
+			wantErr: imageutil.ErrInvalidSVG,
+		},
+		{
+			name:    "mismatched end element",
+			give:    `<svg><g></a></svg>`,
+			wantErr: imageutil.ErrInvalidSVG,
+		},
+		{
+			name:    "unclosed element",
+			give:    `<svg><g>`,
+			wantErr: imageutil.ErrInvalidSVG,
+		},
+		{
+			name:    "not an SVG document",
+			give:    `<html><body/></html>`,
+			wantErr: imageutil.ErrInvalidSVG,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := imageutil.SanitizeSVG([]byte(tt.give))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SanitizeSVG() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("SanitizeSVG() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
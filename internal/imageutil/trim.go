@@ -0,0 +1,126 @@
+package imageutil
+
+import (
+	"fmt"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// Padding represents the space added around an image. Top and Bottom
+// percentages are relative to the image height, and Left and Right ones to
+// its width.
+type Padding struct {
+	Top    Length
+	Right  Length
+	Bottom Length
+	Left   Length
+}
+
+// ParsePadding parses padding in the "top,right,bottom,left" format, where
+// every value is a number of pixels or a percentage such as "5%". A single
+// value is used for every side.
+func ParsePadding(value string) (Padding, error) {
+	lengths, err := parseLengths(value, 4)
+	if err != nil {
+		single, singleErr := parseLengths(value, 1)
+		if singleErr != nil {
+			return Padding{}, fmt.Errorf("%w: %w", ErrInvalidPadding, err)
+		}
+
+		lengths = []Length{single[0], single[0], single[0], single[0]}
+	}
+
+	return Padding{
+		Top:    lengths[0],
+		Right:  lengths[1],
+		Bottom: lengths[2],
+		Left:   lengths[3],
+	}, nil
+}
+
+// trim removes the borders of the image that differ from the color of its
+// top-left pixel by less than threshold. It returns the position of the
+// remaining area's top-left corner.
+func (i *Image) trim(threshold float64) (point, error) {
+	sample, err := i.reference.Copy()
+	if err != nil {
+		return point{}, fmt.Errorf("%w", err)
+	}
+	defer sample.Close()
+
+	// Borders are detected on the first frame of animated images, on an
+	// opaque sRGB copy so the background always has three bands.
+	if sample.Pages() > 1 {
+		frameHeight := sample.PageHeight()
+
+		if err = sample.SetPageHeight(sample.Height()); err != nil {
+			return point{}, fmt.Errorf("%w", err)
+		}
+
+		if err = sample.ExtractArea(0, 0, sample.Width(), frameHeight); err != nil {
+			return point{}, fmt.Errorf("%w", err)
+		}
+	}
+
+	if err = sample.ToColorSpace(vips.InterpretationSRGB); err != nil {
+		return point{}, fmt.Errorf("%w", err)
+	}
+
+	if sample.HasAlpha() {
+		if err = sample.Flatten(&vips.Color{R: 255, G: 255, B: 255}); err != nil {
+			return point{}, fmt.Errorf("%w", err)
+		}
+	}
+
+	pixel, err := sample.GetPoint(0, 0)
+	if err != nil {
+		return point{}, fmt.Errorf("%w", err)
+	}
+
+	background := &vips.Color{
+		R: uint8(clamp(int(pixel[0]), 0, 255)),
+		G: uint8(clamp(int(pixel[1]), 0, 255)),
+		B: uint8(clamp(int(pixel[2]), 0, 255)),
+	}
+
+	left, top, width, height, err := sample.FindTrim(threshold, background)
+	if err != nil {
+		return point{}, fmt.Errorf("%w", err)
+	}
+
+	// A uniform image has nothing but borders, so it's kept as is.
+	if width <= 0 || height <= 0 {
+		return point{}, nil
+	}
+
+	if err = i.reference.ExtractArea(left, top, width, height); err != nil {
+		return point{}, fmt.Errorf("%w", err)
+	}
+
+	return point{x: left, y: top}, nil
+}
+
+// pad adds padding around the image, filled with background. It returns the
+// position of the original image within the padded one.
+func (i *Image) pad(padding Padding, background Color) (point, error) {
+	var (
+		width  = i.reference.Width()
+		height = i.reference.PageHeight()
+		top    = padding.Top.resolve(height)
+		right  = padding.Right.resolve(width)
+		bottom = padding.Bottom.resolve(height)
+		left   = padding.Left.resolve(width)
+	)
+
+	if background.A < 255 && !i.reference.HasAlpha() {
+		if err := i.reference.AddAlpha(); err != nil {
+			return point{}, fmt.Errorf("%w", err)
+		}
+	}
+
+	if err := i.reference.EmbedBackgroundRGBA(left, top, width+left+right, height+top+bottom, background.vips()); err != nil {
+		return point{}, fmt.Errorf("%w", err)
+	}
+
+	return point{x: left, y: top}, nil
+}
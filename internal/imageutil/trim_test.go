@@ -0,0 +1,67 @@
+package imageutil_test
+
+import (
+	"errors"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
+)
+
+func TestParsePadding(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		give    string
+		want    imageutil.Padding
+		wantErr error
+	}{
+		{
+			name: "every side",
+			give: "10, 20, 30, 5%",
+			want: imageutil.Padding{
+				Top:    imageutil.Length{Value: 10},
+				Right:  imageutil.Length{Value: 20},
+				Bottom: imageutil.Length{Value: 30},
+				Left:   imageutil.Length{Value: 5, Percent: true},
+			},
+		},
+		{
+			name: "single value",
+			give: "8",
+			want: imageutil.Padding{
+				Top:    imageutil.Length{Value: 8},
+				Right:  imageutil.Length{Value: 8},
+				Bottom: imageutil.Length{Value: 8},
+				Left:   imageutil.Length{Value: 8},
+			},
+		},
+		{
+			name:    "two values",
+			give:    "8,16",
+			wantErr: imageutil.ErrInvalidPadding,
+		},
+		{
+			name:    "negative value",
+			give:    "0,0,-1,0",
+			wantErr: imageutil.ErrInvalidPadding,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := imageutil.ParsePadding(tt.give)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ParsePadding() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("ParsePadding() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
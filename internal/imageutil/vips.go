@@ -0,0 +1,30 @@
+package imageutil
+
+import (
+	"runtime"
+
+	"github.com/davidbyttow/govips/v2/vips"
+	"go.uber.org/zap"
+)
+
+// vipsCacheSize is the maximum amount of memory, in bytes, libvips uses to
+// cache the results of operations.
+const vipsCacheSize int = 1024 * 1024 * 1024
+
+// Startup initializes libvips, sending its errors to logger. It must be called
+// once before any image is opened.
+func Startup(logger *zap.Logger) {
+	vips.LoggingSettings(func(domain string, _ vips.LogLevel, message string) {
+		logger.Error(message, zap.String("domain", domain))
+	}, vips.LogLevelError)
+
+	vips.Startup(&vips.Config{
+		ConcurrencyLevel: runtime.NumCPU(),
+		MaxCacheMem:      vipsCacheSize,
+	})
+}
+
+// Shutdown shuts libvips down. No image can be opened afterwards.
+func Shutdown() {
+	vips.Shutdown()
+}
@@ -0,0 +1,116 @@
+package imageutil
+
+import (
+	"fmt"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// Watermark represents an image composited onto the processed image.
+type Watermark struct {
+	// Data is the encoded watermark image. If empty, no watermark is applied.
+	Data []byte
+
+	// Position is where the watermark is placed. Smart gravities are not
+	// supported. If empty, GravityCentre is used.
+	Position Gravity
+
+	// Opacity is the opacity of the watermark, from 0 to 1.
+	Opacity float64
+
+	// Scale is the width of the watermark relative to the width of the image,
+	// from 0 to 1. If zero, the watermark is kept at its own size. Either way,
+	// it's shrunk to fit within the margins.
+	Scale float64
+
+	// Margin is the distance between the watermark and the edges of the
+	// image, in pixels.
+	Margin int
+}
+
+// ValidateWatermark returns ErrInvalidWatermark if data cannot be decoded as
+// a watermark image.
+func ValidateWatermark(data []byte) error {
+	overlay, err := vips.LoadImageFromBuffer(data, vips.NewImportParams())
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidWatermark, err)
+	}
+
+	overlay.Close()
+
+	return nil
+}
+
+// watermark composites wm onto every frame of the image.
+func (i *Image) watermark(wm *Watermark) error {
+	overlay, err := vips.LoadImageFromBuffer(wm.Data, vips.NewImportParams())
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidWatermark, err)
+	}
+	defer overlay.Close()
+
+	var (
+		width  = i.reference.Width()
+		height = i.reference.PageHeight()
+		inner  = width - 2*wm.Margin
+		outer  = height - 2*wm.Margin
+		scale  = 1.0
+	)
+
+	if inner <= 0 || outer <= 0 {
+		return nil
+	}
+
+	if wm.Scale > 0 {
+		scale = wm.Scale * float64(width) / float64(overlay.Width())
+	}
+
+	scale = min(scale, float64(inner)/float64(overlay.Width()), float64(outer)/float64(overlay.Height()))
+
+	if scale != 1 {
+		if err = overlay.Resize(scale, vips.KernelAuto); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if err = overlay.ToColorSpace(vips.InterpretationSRGB); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if !overlay.HasAlpha() {
+		if err = overlay.AddAlpha(); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if wm.Opacity < 1 {
+		if err = overlay.Linear([]float64{1, 1, 1, wm.Opacity}, []float64{0, 0, 0, 0}); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		if err = overlay.Cast(vips.BandFormatUchar); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	left, top := wm.Position.offset(inner, outer, overlay.Width(), overlay.Height())
+
+	// The overlay is placed on a transparent canvas the size of a frame, and
+	// the canvas repeated for every frame, so animated images are watermarked
+	// on every frame.
+	if err = overlay.EmbedBackgroundRGBA(left+wm.Margin, top+wm.Margin, width, height, &vips.ColorRGBA{}); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if pages := i.reference.Height() / height; pages > 1 {
+		if err = overlay.Replicate(1, pages); err != nil {
+			return fmt.Errorf("%w", err)
+		}
+	}
+
+	if err = i.reference.Composite(overlay, vips.BlendModeOver, 0, 0); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
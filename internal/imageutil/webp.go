@@ -0,0 +1,192 @@
+package imageutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Layout of WebP images. See
+// https://developers.google.com/speed/webp/docs/riff_container.
+const (
+	// webpHeaderSize is the size of the RIFF header of a WebP image.
+	webpHeaderSize int = 12
+
+	// webpChunkHeaderSize is the size of the FourCC and size of a chunk.
+	webpChunkHeaderSize int = 8
+
+	// webpExtendedSize is the size of the payload of a VP8X chunk.
+	webpExtendedSize int = 10
+
+	// webpICCFlag and webpAlphaFlag are the bits of the VP8X flags set when
+	// the image has an ICC profile and an alpha channel, respectively.
+	webpICCFlag   byte = 0x20
+	webpAlphaFlag byte = 0x10
+)
+
+// AlphaLevels returns the number of levels the alpha channel of a lossy WebP
+// image is quantized to at the given alpha quality, using the same mapping as
+// the alpha_q option of libwebp: qualities up to 70 map to 2 to 16 levels and
+// higher ones to up to 256 levels. It returns zero if the alpha channel is
+// kept unchanged, i.e. for a quality of zero or 100.
+//
+// govips doesn't expose alpha_q, so the alpha channel is quantized before the
+// image is encoded, and libwebp then compresses it losslessly.
+func AlphaLevels(quality uint) int {
+	switch {
+	case quality == 0 || quality >= MaxQuality:
+		return 0
+	case quality <= 70:
+		return 2 + int(quality)/5
+	default:
+		return 16 + (int(quality)-70)*8
+	}
+}
+
+// webpChunk is a chunk of a WebP image.
+type webpChunk struct {
+	fourCC  string
+	payload []byte
+}
+
+// EmbedWebPProfile embeds an ICC profile in a WebP image, replacing the one
+// it already has, if any. Images in the simple format are converted to the
+// extended format, which is the only one able to hold a profile.
+//
+// govips always embeds its own profile, or none, when encoding WebP images,
+// so the profile of the image has to be added afterwards.
+func EmbedWebPProfile(data, profile []byte) ([]byte, error) {
+	chunks, err := parseWebP(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if chunks[0].fourCC != "VP8X" {
+		extended, err := extendedChunk(chunks[0])
+		if err != nil {
+			return nil, err
+		}
+
+		chunks = append([]webpChunk{extended}, chunks...)
+	}
+
+	chunks[0].payload = bytes.Clone(chunks[0].payload)
+	chunks[0].payload[0] |= webpICCFlag
+
+	embedded := []webpChunk{chunks[0], {fourCC: "ICCP", payload: profile}}
+
+	for _, chunk := range chunks[1:] {
+		if chunk.fourCC != "ICCP" {
+			embedded = append(embedded, chunk)
+		}
+	}
+
+	var buffer bytes.Buffer
+
+	buffer.WriteString("RIFF")
+	buffer.Write([]byte{0, 0, 0, 0})
+	buffer.WriteString("WEBP")
+
+	for _, chunk := range embedded {
+		buffer.WriteString(chunk.fourCC)
+		_ = binary.Write(&buffer, binary.LittleEndian, uint32(len(chunk.payload)))
+		buffer.Write(chunk.payload)
+
+		if len(chunk.payload)%2 == 1 {
+			buffer.WriteByte(0)
+		}
+	}
+
+	image := buffer.Bytes()
+	binary.LittleEndian.PutUint32(image[4:], uint32(len(image)-webpChunkHeaderSize))
+
+	return image, nil
+}
+
+// parseWebP splits a WebP image into its chunks.
+func parseWebP(data []byte) ([]webpChunk, error) {
+	if len(data) < webpHeaderSize || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("%w: missing RIFF header", ErrInvalidWebP)
+	}
+
+	var (
+		chunks []webpChunk
+		offset = webpHeaderSize
+	)
+
+	for offset+webpChunkHeaderSize <= len(data) {
+		var (
+			fourCC = string(data[offset : offset+4])
+			size   = int(binary.LittleEndian.Uint32(data[offset+4:]))
+			start  = offset + webpChunkHeaderSize
+		)
+
+		if size > len(data)-start {
+			return nil, fmt.Errorf("%w: truncated %s chunk", ErrInvalidWebP, fourCC)
+		}
+
+		chunks = append(chunks, webpChunk{fourCC: fourCC, payload: data[start : start+size]})
+
+		offset = start + size + size%2
+	}
+
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("%w: no chunks", ErrInvalidWebP)
+	}
+
+	return chunks, nil
+}
+
+// extendedChunk returns the VP8X chunk describing an image in the simple
+// format, whose only chunk is given.
+func extendedChunk(chunk webpChunk) (webpChunk, error) {
+	var (
+		width, height int
+		flags         byte
+	)
+
+	switch chunk.fourCC {
+	case "VP8 ":
+		if len(chunk.payload) < 10 || !bytes.Equal(chunk.payload[3:6], []byte{0x9d, 0x01, 0x2a}) {
+			return webpChunk{}, fmt.Errorf("%w: invalid VP8 frame header", ErrInvalidWebP)
+		}
+
+		width = int(binary.LittleEndian.Uint16(chunk.payload[6:]) & 0x3fff)
+		height = int(binary.LittleEndian.Uint16(chunk.payload[8:]) & 0x3fff)
+	case "VP8L":
+		if len(chunk.payload) < 5 || chunk.payload[0] != 0x2f {
+			return webpChunk{}, fmt.Errorf("%w: invalid VP8L header", ErrInvalidWebP)
+		}
+
+		bits := binary.LittleEndian.Uint32(chunk.payload[1:])
+
+		width = int(bits&0x3fff) + 1
+		height = int(bits>>14&0x3fff) + 1
+
+		if bits>>28&1 == 1 {
+			flags |= webpAlphaFlag
+		}
+	default:
+		return webpChunk{}, fmt.Errorf("%w: unexpected %s chunk", ErrInvalidWebP, chunk.fourCC)
+	}
+
+	if width < 1 || height < 1 {
+		return webpChunk{}, fmt.Errorf("%w: empty image", ErrInvalidWebP)
+	}
+
+	payload := make([]byte, webpExtendedSize)
+	payload[0] = flags
+
+	putUint24(payload[4:], uint32(width-1))
+	putUint24(payload[7:], uint32(height-1))
+
+	return webpChunk{fourCC: "VP8X", payload: payload}, nil
+}
+
+// putUint24 writes value to the first three bytes of b in little endian
+// order.
+func putUint24(b []byte, value uint32) {
+	b[0] = byte(value)
+	b[1] = byte(value >> 8)
+	b[2] = byte(value >> 16)
+}
@@ -0,0 +1,177 @@
+package imageutil_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
+)
+
+// webpChunk returns a WebP chunk, padded to an even size.
+func webpChunk(fourCC string, payload []byte) []byte {
+	chunk := append([]byte(fourCC), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(payload)))
+	chunk = append(chunk, payload...)
+
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+
+	return chunk
+}
+
+// webpImage returns a WebP image made of the given chunks.
+func webpImage(chunks ...[]byte) []byte {
+	body := bytes.Join(chunks, nil)
+
+	image := append([]byte("RIFF"), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(image[4:], uint32(len(body)+4))
+	image = append(image, "WEBP"...)
+
+	return append(image, body...)
+}
+
+func TestEmbedWebPProfile(t *testing.T) {
+	t.Parallel()
+
+	var (
+		profile = []byte("profile")
+		lossy   = webpChunk("VP8 ", []byte{0, 0, 0, 0x9d, 0x01, 0x2a, 3, 0, 2, 0, 0xaa})
+		// Signature, then a 3x2 image with alpha: width-1 in bits 0-13,
+		// height-1 in bits 14-27 and the alpha bit at 28.
+		lossless = webpChunk("VP8L", []byte{0x2f, 0x02, 0x40, 0x00, 0x10, 0xbb})
+		exif     = webpChunk("EXIF", []byte("exif"))
+	)
+
+	tests := []struct {
+		name    string
+		give    []byte
+		want    []byte
+		wantErr error
+	}{
+		{
+			name: "lossy simple format",
+			give: webpImage(lossy),
+			want: webpImage(
+				webpChunk("VP8X", []byte{0x20, 0, 0, 0, 2, 0, 0, 1, 0, 0}),
+				webpChunk("ICCP", profile),
+				lossy,
+			),
+		},
+		{
+			name: "lossless simple format with alpha",
+			give: webpImage(lossless),
+			want: webpImage(
+				webpChunk("VP8X", []byte{0x30, 0, 0, 0, 2, 0, 0, 1, 0, 0}),
+				webpChunk("ICCP", profile),
+				lossless,
+			),
+		},
+		{
+			name: "extended format with a profile",
+			give: webpImage(
+				webpChunk("VP8X", []byte{0x28, 0, 0, 0, 2, 0, 0, 1, 0, 0}),
+				webpChunk("ICCP", []byte("old")),
+				lossy,
+				exif,
+			),
+			want: webpImage(
+				webpChunk("VP8X", []byte{0x28, 0, 0, 0, 2, 0, 0, 1, 0, 0}),
+				webpChunk("ICCP", profile),
+				lossy,
+				exif,
+			),
+		},
+		{
+			name: "extended format without a profile",
+			give: webpImage(
+				webpChunk("VP8X", []byte{0x08, 0, 0, 0, 2, 0, 0, 1, 0, 0}),
+				lossy,
+				exif,
+			),
+			want: webpImage(
+				webpChunk("VP8X", []byte{0x28, 0, 0, 0, 2, 0, 0, 1, 0, 0}),
+				webpChunk("ICCP", profile),
+				lossy,
+				exif,
+			),
+		},
+		{
+			name:    "not a WebP image",
+			give:    []byte("\x89PNG\r\n\x1a\n"),
+			wantErr: imageutil.ErrInvalidWebP,
+		},
+		{
+			name:    "truncated chunk",
+			give:    webpImage(lossy)[:20],
+			wantErr: imageutil.ErrInvalidWebP,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := imageutil.EmbedWebPProfile(tt.give, profile)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("EmbedWebPProfile() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("EmbedWebPProfile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlphaLevels(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		quality uint
+		want    int
+	}{
+		{
+			name:    "unset",
+			quality: 0,
+			want:    0,
+		},
+		{
+			name:    "lowest quality",
+			quality: 1,
+			want:    2,
+		},
+		{
+			name:    "moderate quality",
+			quality: 70,
+			want:    16,
+		},
+		{
+			name:    "high quality",
+			quality: 99,
+			want:    248,
+		},
+		{
+			name:    "lossless",
+			quality: 100,
+			want:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := imageutil.AlphaLevels(tt.quality); got != tt.want {
+				t.Errorf("AlphaLevels(%d) = %d, want %d", tt.quality, got, tt.want)
+			}
+		})
+	}
+}
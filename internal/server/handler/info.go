@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/config"
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/fetch"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"go.uber.org/zap"
+)
+
+// InfoResponse is the response for the /info endpoint.
+type InfoResponse struct {
+	// Format is the format of the image.
+	Format string `json:"format"`
+
+	// Colorspace is the color space of the image.
+	Colorspace string `json:"colorspace"`
+
+	// ICCProfile is the description of the ICC profile embedded in the image.
+	ICCProfile string `json:"iccProfile,omitempty"`
+
+	// Width is the width of the image.
+	Width int `json:"width"`
+
+	// Height is the height of the image, or of a single frame for animated
+	// images.
+	Height int `json:"height"`
+
+	// Frames is the number of frames in the image.
+	Frames int `json:"frames"`
+
+	// BitDepth is the number of bits per channel.
+	BitDepth int `json:"bitDepth"`
+
+	// Orientation is the EXIF orientation of the image.
+	Orientation int `json:"orientation,omitempty"`
+
+	// Size is the size of the image in bytes.
+	Size int `json:"size"`
+
+	// Alpha reports whether the image has an alpha channel.
+	Alpha bool `json:"alpha"`
+}
+
+// InfoHandler is an HTTP handler for the /info endpoint.
+type InfoHandler struct {
+	cfg         *config.Config
+	fetchClient *fetch.Client
+	logger      *zap.Logger
+}
+
+// NewInfoHandler creates a new instance of InfoHandler.
+func NewInfoHandler(cfg *config.Config, fetchClient *fetch.Client, logger *zap.Logger) *InfoHandler {
+	return &InfoHandler{
+		cfg:         cfg,
+		fetchClient: fetchClient,
+		logger:      logger,
+	}
+}
+
+// ServeHTTP serves the /info endpoint.
+func (h *InfoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	file, _, ok := readInput(w, r, h.cfg, h.fetchClient, h.logger)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	img, ok := openImage(w, file, h.logger)
+	if !ok {
+		return
+	}
+	defer img.Close()
+
+	info := img.Info()
+
+	w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
+
+	err := json.NewEncoder(w).Encode(InfoResponse{
+		Format:      string(info.Format),
+		Colorspace:  info.Colorspace,
+		ICCProfile:  info.ICCProfile,
+		Width:       info.Width,
+		Height:      info.Height,
+		Frames:      info.Frames,
+		BitDepth:    info.BitDepth,
+		Orientation: info.Orientation,
+		Size:        info.Size,
+		Alpha:       info.Alpha,
+	})
+	if err != nil {
+		h.logger.Error("failed to write response", zap.Error(err))
+
+		return
+	}
+}
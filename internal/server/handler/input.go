@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/config"
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/fetch"
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/serror"
+	"go.uber.org/zap"
+)
+
+// readInput returns the image sent with a request and its file name. The
+// image is either fetched from the URL in the url query parameter or uploaded
+// in the input field of a multipart form. If the image cannot be read, an
+// error response is written to w and ok is false.
+func readInput(w http.ResponseWriter, r *http.Request, cfg *config.Config, fetchClient *fetch.Client, logger *zap.Logger) (file io.ReadCloser, filename string, ok bool) {
+	if r.ContentLength > int64(cfg.Service.MaxUploadSize)<<20 {
+		serror.JSON(w, logger, serror.ErrorResponse{
+			Code:    http.StatusRequestEntityTooLarge,
+			Message: fmt.Sprintf("The image you uploaded is too large. The maximum upload size is %d. Please try again.", cfg.Service.MaxUploadSize),
+		})
+
+		return nil, "", false
+	}
+
+	var (
+		maxUploadSize = cfg.Service.MaxUploadSize * 1024 * 1024
+		uri           = r.URL.Query().Get("url")
+	)
+
+	if uri != "" {
+		data, filename, err := fetchClient.Remote(r.Context(), uri)
+		if err != nil {
+			logger.Error("failed to fetch remote image", zap.Error(err))
+
+			serror.JSON(w, logger, serror.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: "Cannot fetch the remote image. Please provide a valid image and try again.",
+			})
+
+			return nil, "", false
+		}
+
+		if len(data) > int(maxUploadSize) {
+			logger.Error("image size is too large", zap.Error(err))
+
+			serror.JSON(w, logger, serror.ErrorResponse{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("The image size cannot be greater than %d MB. Please provide a valid image and try again.", cfg.Service.MaxUploadSize),
+			})
+
+			return nil, "", false
+		}
+
+		return io.NopCloser(bytes.NewReader(data)), filename, true
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxUploadSize))
+	if err := r.ParseMultipartForm(int64(maxUploadSize)); err != nil {
+		logger.Error("failed to parse multipart form", zap.Error(err))
+
+		serror.JSON(w, logger, serror.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Cannot process the image. Please provide a valid image and try again.",
+		})
+
+		return nil, "", false
+	}
+
+	file, header, err := r.FormFile("input")
+	if err != nil {
+		logger.Error("failed to get input file", zap.Error(err))
+
+		serror.JSON(w, logger, serror.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Cannot process the image. Please provide a valid image and try again.",
+		})
+
+		return nil, "", false
+	}
+
+	return file, header.Filename, true
+}
+
+// openImage decodes the image read from file. If it cannot be decoded, an
+// error response is written to w and ok is false.
+func openImage(w http.ResponseWriter, file io.Reader, logger *zap.Logger) (img *imageutil.Image, ok bool) {
+	img, err := imageutil.Open(file)
+	if err == nil {
+		return img, true
+	}
+
+	if errors.Is(err, imageutil.ErrUnsupportedImageFormat) {
+		logger.Error("unsupported image format", zap.Error(err))
+
+		serror.JSON(w, logger, serror.ErrorResponse{
+			Code:    http.StatusUnsupportedMediaType,
+			Message: fmt.Sprintf("Unsupported image format. Please provide a valid %s image and try again.", imageutil.FormatList(imageutil.InputFormats)),
+		})
+
+		return nil, false
+	}
+
+	if errors.Is(err, imageutil.ErrInvalidSVG) {
+		logger.Error("invalid SVG document", zap.Error(err))
+
+		serror.JSON(w, logger, serror.ErrorResponse{
+			Code:    http.StatusBadRequest,
+			Message: "Cannot parse the SVG image. Please provide a valid SVG document and try again.",
+		})
+
+		return nil, false
+	}
+
+	logger.Error("failed to open image", zap.Error(err))
+
+	serror.JSON(w, logger, serror.ErrorResponse{
+		Code:    http.StatusInternalServerError,
+		Message: "Cannot process the image. Please try again.",
+	})
+
+	return nil, false
+}
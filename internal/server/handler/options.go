@@ -0,0 +1,641 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
+)
+
+// parameterError is returned when a request parameter is invalid.
+type parameterError struct {
+	// err is the underlying error, meant for logging.
+	err error
+
+	// message is a human-readable message describing the error, meant for the
+	// client.
+	message string
+}
+
+// Error implements the error interface.
+func (e *parameterError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *parameterError) Unwrap() error {
+	return e.err
+}
+
+// parseInt parses the integer parameter key from query. It returns fallback
+// if the parameter is missing or outside the range [minimum, maximum], and a
+// *parameterError if it cannot be parsed. The name is used in error messages.
+func parseInt(query url.Values, key, name string, fallback, minimum, maximum int) (int, error) {
+	if query.Get(key) == "" {
+		return fallback, nil
+	}
+
+	value, err := strconv.Atoi(query.Get(key))
+	if err != nil {
+		return 0, &parameterError{
+			err:     fmt.Errorf("failed to parse image %s parameter: %w", name, err),
+			message: fmt.Sprintf("Cannot parse the image %s parameter. Please provide a valid integer and try again.", name),
+		}
+	}
+
+	if value < minimum || value > maximum {
+		return fallback, nil
+	}
+
+	return value, nil
+}
+
+// parseBool parses the boolean parameter key from query. It returns fallback
+// if the parameter is missing and a *parameterError if it cannot be parsed.
+// The name is used in error messages.
+func parseBool(query url.Values, key, name string, fallback bool) (bool, error) {
+	if query.Get(key) == "" {
+		return fallback, nil
+	}
+
+	value, err := strconv.ParseBool(query.Get(key))
+	if err != nil {
+		return false, &parameterError{
+			err:     fmt.Errorf("failed to parse image %s parameter: %w", name, err),
+			message: fmt.Sprintf("Cannot parse the image %s parameter. Please provide a valid boolean and try again.", name),
+		}
+	}
+
+	return value, nil
+}
+
+// parseFloat parses the floating-point parameter key from query. It returns
+// fallback if the parameter is missing or outside the range [minimum,
+// maximum], and a *parameterError if it cannot be parsed or is not finite.
+// The name is used in error messages.
+func parseFloat(query url.Values, key, name string, fallback, minimum, maximum float64) (float64, error) {
+	if query.Get(key) == "" {
+		return fallback, nil
+	}
+
+	value, err := strconv.ParseFloat(query.Get(key), 64)
+	if err == nil && (math.IsNaN(value) || math.IsInf(value, 0)) {
+		err = errors.New("value is not a finite number")
+	}
+
+	if err != nil {
+		return 0, &parameterError{
+			err:     fmt.Errorf("failed to parse image %s parameter: %w", name, err),
+			message: fmt.Sprintf("Cannot parse the image %s parameter. Please provide a valid number and try again.", name),
+		}
+	}
+
+	if value < minimum || value > maximum {
+		return fallback, nil
+	}
+
+	return value, nil
+}
+
+// parseColor parses the hexadecimal color parameter key from query. It uses
+// fallback if the parameter is missing and returns a *parameterError if it
+// cannot be parsed. The name is used in error messages.
+func parseColor(query url.Values, key, name, fallback string) (imageutil.Color, error) {
+	value := query.Get(key)
+	if value == "" {
+		value = fallback
+	}
+
+	color, err := imageutil.ParseColor(value)
+	if err != nil {
+		return imageutil.Color{}, &parameterError{
+			err:     fmt.Errorf("failed to parse image %s parameter: %w", name, err),
+			message: fmt.Sprintf("Cannot parse the image %s parameter. Please provide a valid hexadecimal color, such as #ffffff, and try again.", name),
+		}
+	}
+
+	return color, nil
+}
+
+// parseDimension parses the width or height parameter key from query and
+// multiplies it by the device pixel ratio dpr, ensuring the result is not
+// greater than maximum.
+//
+// If hinted is set, dpr came from a client hint rather than the query, so a
+// valid parameter multiplied past maximum is limited to maximum instead.
+func parseDimension(query url.Values, key string, fallback int, dpr float64, maximum uint, hinted bool) (int, error) {
+	value, err := parseInt(query, key, key, fallback, math.MinInt, math.MaxInt)
+	if err != nil {
+		return 0, err
+	}
+
+	if value < 0 {
+		return fallback, nil
+	}
+
+	scaled := math.Round(float64(value) * dpr)
+
+	if hinted && value <= int(maximum) {
+		return int(math.Min(scaled, float64(maximum))), nil
+	}
+
+	if scaled > float64(maximum) {
+		message := fmt.Sprintf("The image %s parameter cannot be greater than %d. Please provide a valid integer and try again.", key, maximum)
+		if dpr != 1 && !hinted {
+			message = fmt.Sprintf("The image %s parameter multiplied by the device pixel ratio cannot be greater than %d. Please provide a smaller %s or device pixel ratio and try again.", key, maximum, key)
+		}
+
+		return 0, &parameterError{
+			err:     fmt.Errorf("image %s parameter is greater than %d", key, maximum),
+			message: message,
+		}
+	}
+
+	return int(scaled), nil
+}
+
+// clientHint returns the value of the first of the given client hint headers
+// present in header.
+func clientHint(header http.Header, names ...string) string {
+	for _, name := range names {
+		if value := strings.TrimSpace(header.Get(name)); value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// hintedWidth returns the width requested by the client hints in header, in
+// physical pixels and limited to the maximum allowed width. It returns zero if
+// the client sent no width hints.
+func (h *ShrinkHandler) hintedWidth(header http.Header, dpr float64) int {
+	maximum := int(h.cfg.Service.MaxAllowedWidth)
+
+	if width, err := strconv.Atoi(clientHint(header, "Sec-CH-Width", "Width")); err == nil && width > 0 {
+		return min(width, maximum)
+	}
+
+	if viewport, err := strconv.Atoi(clientHint(header, "Sec-CH-Viewport-Width", "Viewport-Width")); err == nil && viewport > 0 {
+		return min(int(math.Round(float64(viewport)*dpr)), maximum)
+	}
+
+	return 0
+}
+
+// pixels returns length in pixels, or zero if it's a percentage. Percentages
+// are limited to 100% of the image dimensions by imageutil.ParsePadding.
+func pixels(length imageutil.Length) float64 {
+	if length.Percent {
+		return 0
+	}
+
+	return length.Value
+}
+
+// parseOptions builds the image processing options from the query parameters
+// of a request to the /shrink endpoint, falling back to the client hints in
+// header for the device pixel ratio and width.
+func (h *ShrinkHandler) parseOptions(query url.Values, header http.Header) (*imageutil.Options, error) {
+	var (
+		options = &imageutil.Options{
+			StripMetadata: DefaultStripMetadata,
+		}
+		err error
+	)
+
+	if query.Get("format") != "" {
+		options.Format, err = imageutil.ParseFormat(query.Get("format"))
+		if err != nil {
+			return nil, &parameterError{
+				err:     fmt.Errorf("failed to parse image format parameter: %w", err),
+				message: fmt.Sprintf("Unsupported output format. Please choose auto, %s and try again.", imageutil.FormatList(imageutil.OutputFormats)),
+			}
+		}
+	}
+
+	quality, err := parseInt(query, "quality", "quality", DefaultQuality, 1, 100)
+	if err != nil {
+		return nil, err
+	}
+
+	compression, err := parseInt(query, "compression", "compression", DefaultCompression, 0, 9)
+	if err != nil {
+		return nil, err
+	}
+
+	quantTable, err := parseInt(query, "quant_table", "quantization table", DefaultQuantTable, 0, 8)
+	if err != nil {
+		return nil, err
+	}
+
+	effort, err := parseInt(query, "effort", "effort", DefaultEffort, 0, int(imageutil.MaxEffort))
+	if err != nil {
+		return nil, err
+	}
+
+	maxBytes, err := parseInt(query, "max_bytes", "max bytes", DefaultMaxBytes, 0, math.MaxInt)
+	if err != nil {
+		return nil, err
+	}
+
+	targetSSIM, err := parseFloat(query, "target_ssim", "target SSIM", DefaultTargetSSIM, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxBytes > 0 && targetSSIM > 0 {
+		return nil, &parameterError{
+			err:     errors.New("image max bytes and target SSIM parameters are mutually exclusive"),
+			message: "The image max bytes and target SSIM parameters cannot be used together. Please provide only one of them and try again.",
+		}
+	}
+
+	if strings.EqualFold(clientHint(header, "Save-Data"), "on") {
+		quality = max(1, int(math.Round(float64(quality)*SaveDataQualityFactor)))
+	}
+
+	alphaQuality, err := parseInt(query, "alpha_quality", "alpha quality", DefaultAlphaQuality, 1, 100)
+	if err != nil {
+		return nil, err
+	}
+
+	options.Quality = uint(quality)
+	options.AlphaQuality = uint(alphaQuality)
+	options.Compression = uint(compression)
+	options.QuantTable = uint(quantTable)
+	options.Effort = uint(effort)
+	options.MaxBytes = maxBytes
+	options.TargetSSIM = targetSSIM
+
+	if options.OptimizeCoding, err = parseBool(query, "optimize_coding", "optimize coding", DefaultOptimizeCoding); err != nil {
+		return nil, err
+	}
+
+	if options.Interlaced, err = parseBool(query, "interlace", "interlace", DefaultInterlace); err != nil {
+		return nil, err
+	}
+
+	// progressive used to be controlled by interlace, so it defaults to it.
+	if options.Progressive, err = parseBool(query, "progressive", "progressive", options.Interlaced); err != nil {
+		return nil, err
+	}
+
+	options.Subsampling = DefaultSubsampling
+
+	if query.Get("subsampling") != "" {
+		options.Subsampling, err = imageutil.ParseSubsampling(query.Get("subsampling"))
+		if err != nil {
+			return nil, &parameterError{
+				err:     fmt.Errorf("failed to parse image subsampling parameter: %w", err),
+				message: "Unsupported chroma subsampling. 4:2:2 subsampling is not supported since libvips cannot encode it. Please choose 444 or 420 and try again.",
+			}
+		}
+	}
+
+	// Subsampling is only rejected for formats the caller asked for. A format
+	// picked by negotiation or from the input image just ignores it.
+	if options.Subsampling != "" && options.Format != "" && options.Format != imageutil.FormatAuto && options.Format != imageutil.FormatJPEG {
+		return nil, &parameterError{
+			err:     fmt.Errorf("%w: %s", imageutil.ErrSubsamplingFormat, options.Format),
+			message: fmt.Sprintf("The subsampling parameter only applies to JPEG images, but the %s format was requested. Please choose the jpeg format or remove the subsampling parameter and try again.", options.Format),
+		}
+	}
+
+	if query.Get("keep") != "" {
+		options.Keep, err = imageutil.ParseMetadata(query.Get("keep"))
+		if err != nil {
+			return nil, &parameterError{
+				err:     fmt.Errorf("failed to parse image keep parameter: %w", err),
+				message: "Unsupported metadata in the image keep parameter. Please choose from all, copyright, creator, icc, orientation, exif, gps, xmp, and iptc and try again.",
+			}
+		}
+
+		options.StripMetadata = false
+	}
+
+	if options.OptimizeICCProfile, err = parseBool(query, "optimize_icc_profile", "optimize ICC profile", DefaultOptimizeICCProfile); err != nil {
+		return nil, err
+	}
+
+	options.Colorspace = DefaultColorspace
+
+	if query.Get("colorspace") != "" {
+		options.Colorspace, err = imageutil.ParseColorspace(query.Get("colorspace"))
+		if err != nil {
+			return nil, &parameterError{
+				err:     fmt.Errorf("failed to parse image colorspace parameter: %w", err),
+				message: "Unsupported color space. Please choose srgb, p3, or cmyk-to-srgb and try again.",
+			}
+		}
+	}
+
+	if options.TrellisQuant, err = parseBool(query, "trellis_quant", "trellis quant", DefaultTrellisQuant); err != nil {
+		return nil, err
+	}
+
+	if options.OvershootDeringing, err = parseBool(query, "overshoot_deringing", "overshoot deringing", DefaultOvershootDeringing); err != nil {
+		return nil, err
+	}
+
+	if options.OptimizeScans, err = parseBool(query, "optimize_scans", "optimize scans", DefaultOptimizeScans); err != nil {
+		return nil, err
+	}
+
+	if options.Lossless, err = parseBool(query, "lossless", "lossless", DefaultLossless); err != nil {
+		return nil, err
+	}
+
+	if options.NearLossless, err = parseBool(query, "near_lossless", "near lossless", DefaultNearLossless); err != nil {
+		return nil, err
+	}
+
+	if options.Palette, err = parseBool(query, "palette", "palette", DefaultPalette); err != nil {
+		return nil, err
+	}
+
+	// Unlike most parameters, out of range values are rejected rather than
+	// replaced by the default, as the palette sizes are not a range.
+	colors, err := parseInt(query, "colors", "colors", DefaultColors, math.MinInt, math.MaxInt)
+	if err != nil {
+		return nil, err
+	}
+
+	if colors < 0 || !imageutil.ValidColors(uint(colors)) {
+		return nil, &parameterError{
+			err:     fmt.Errorf("failed to parse image colors parameter: %w: %d", imageutil.ErrUnsupportedColors, colors),
+			message: "Unsupported number of colors. Please choose 2, 4, 16, or 256 and try again.",
+		}
+	}
+
+	options.Colors = uint(colors)
+
+	if options.Dither, err = parseFloat(query, "dither", "dither", DefaultDither, 0, 1); err != nil {
+		return nil, err
+	}
+
+	dpr, err := parseFloat(query, "dpr", "device pixel ratio", DefaultDPR, MinDPR, MaxDPR)
+	if err != nil {
+		return nil, err
+	}
+
+	// Client hints apply only when the parameters are absent, and must not
+	// turn an otherwise valid request into an error.
+	hinted := false
+
+	if query.Get("dpr") == "" {
+		hint, err := strconv.ParseFloat(clientHint(header, "Sec-CH-DPR", "DPR"), 64)
+		if err == nil && !math.IsNaN(hint) && !math.IsInf(hint, 0) {
+			dpr = math.Min(math.Max(hint, MinDPR), MaxDPR)
+			hinted = true
+		}
+	}
+
+	width, err := parseDimension(query, "width", DefaultWidth, dpr, h.cfg.Service.MaxAllowedWidth, hinted)
+	if err != nil {
+		return nil, err
+	}
+
+	height, err := parseDimension(query, "height", DefaultHeight, dpr, h.cfg.Service.MaxAllowedHeight, hinted)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Get("width") == "" && query.Get("height") == "" {
+		width = h.hintedWidth(header, dpr)
+	}
+
+	options.Width = uint(width)
+	options.Height = uint(height)
+	options.Fit = DefaultFit
+
+	if options.AutoOrient, err = parseBool(query, "auto_orient", "auto orient", DefaultAutoOrient); err != nil {
+		return nil, err
+	}
+
+	rotate, err := parseInt(query, "rotate", "rotate", DefaultRotate, math.MinInt, math.MaxInt)
+	if err != nil {
+		return nil, err
+	}
+
+	if rotate < 0 || !imageutil.ValidRotation(uint(rotate)) {
+		return nil, &parameterError{
+			err:     fmt.Errorf("failed to parse image rotate parameter: %w: %d", imageutil.ErrUnsupportedRotation, rotate),
+			message: "Unsupported rotation. Please choose 0, 90, 180, or 270 and try again.",
+		}
+	}
+
+	options.Rotate = uint(rotate)
+
+	if query.Get("flip") != "" {
+		options.Flip, err = imageutil.ParseFlip(query.Get("flip"))
+		if err != nil {
+			return nil, &parameterError{
+				err:     fmt.Errorf("failed to parse image flip parameter: %w", err),
+				message: "Unsupported flip direction. Please choose h or v and try again.",
+			}
+		}
+	}
+
+	if query.Get("crop") != "" {
+		region, err := imageutil.ParseRegion(query.Get("crop"))
+		if err != nil {
+			return nil, &parameterError{
+				err:     fmt.Errorf("failed to parse image crop parameter: %w", err),
+				message: "Cannot parse the image crop parameter. Please provide it as x,y,width,height in pixels or percentages and try again.",
+			}
+		}
+
+		options.Crop = &region
+	}
+
+	if query.Get("focus") != "" {
+		focus, err := imageutil.ParsePoint(query.Get("focus"))
+		if err != nil {
+			return nil, &parameterError{
+				err:     fmt.Errorf("failed to parse image focus parameter: %w", err),
+				message: "Cannot parse the image focus parameter. Please provide it as x,y in pixels or percentages and try again.",
+			}
+		}
+
+		options.Focus = &focus
+	}
+
+	if options.Trim, err = parseFloat(query, "trim", "trim", DefaultTrim, 0, MaxTrim); err != nil {
+		return nil, err
+	}
+
+	if query.Get("pad") != "" {
+		padding, err := imageutil.ParsePadding(query.Get("pad"))
+		if err != nil {
+			return nil, &parameterError{
+				err:     fmt.Errorf("failed to parse image pad parameter: %w", err),
+				message: "Cannot parse the image pad parameter. Please provide it as top,right,bottom,left in pixels or percentages and try again.",
+			}
+		}
+
+		var (
+			horizontal = pixels(padding.Left) + pixels(padding.Right)
+			vertical   = pixels(padding.Top) + pixels(padding.Bottom)
+		)
+
+		if horizontal > float64(h.cfg.Service.MaxAllowedWidth) || vertical > float64(h.cfg.Service.MaxAllowedHeight) {
+			return nil, &parameterError{
+				err:     fmt.Errorf("image pad parameter is greater than %dx%d", h.cfg.Service.MaxAllowedWidth, h.cfg.Service.MaxAllowedHeight),
+				message: fmt.Sprintf("The image pad parameter cannot add more than %d pixels horizontally or %d pixels vertically. Please provide a smaller padding and try again.", h.cfg.Service.MaxAllowedWidth, h.cfg.Service.MaxAllowedHeight),
+			}
+		}
+
+		options.Pad = &padding
+	}
+
+	options.MaxWidth = h.cfg.Service.MaxAllowedWidth
+	options.MaxHeight = h.cfg.Service.MaxAllowedHeight
+
+	if query.Get("fit") != "" {
+		options.Fit, err = imageutil.ParseFit(query.Get("fit"))
+		if err != nil {
+			return nil, &parameterError{
+				err:     fmt.Errorf("failed to parse image fit parameter: %w", err),
+				message: "Unsupported fit mode. Please choose cover, contain, fill, inside, or outside and try again.",
+			}
+		}
+	}
+
+	options.Gravity = DefaultGravity
+
+	if query.Get("gravity") != "" {
+		options.Gravity, err = imageutil.ParseGravity(query.Get("gravity"))
+		if err != nil {
+			return nil, &parameterError{
+				err:     fmt.Errorf("failed to parse image gravity parameter: %w", err),
+				message: "Unsupported gravity. Please choose centre, north, north-east, east, south-east, south, south-west, west, north-west, smart, attention, or entropy and try again.",
+			}
+		}
+	}
+
+	if options.Background, err = parseColor(query, "background", "background", DefaultBackground); err != nil {
+		return nil, err
+	}
+
+	if options.Grayscale, err = parseBool(query, "grayscale", "grayscale", DefaultGrayscale); err != nil {
+		return nil, err
+	}
+
+	if query.Get("tint") != "" {
+		tint, err := parseColor(query, "tint", "tint", "")
+		if err != nil {
+			return nil, err
+		}
+
+		options.Tint = &tint
+	}
+
+	if options.Brightness, err = parseFloat(query, "brightness", "brightness", DefaultBrightness, -1, 1); err != nil {
+		return nil, err
+	}
+
+	if options.Contrast, err = parseFloat(query, "contrast", "contrast", DefaultContrast, -1, 1); err != nil {
+		return nil, err
+	}
+
+	if options.Gamma, err = parseFloat(query, "gamma", "gamma", DefaultGamma, MinGamma, MaxGamma); err != nil {
+		return nil, err
+	}
+
+	if options.Blur, err = parseFloat(query, "blur", "blur", DefaultBlur, 0, MaxBlur); err != nil {
+		return nil, err
+	}
+
+	if options.Sharpen, err = parseFloat(query, "sharpen", "sharpen", DefaultSharpen, 0, MaxSharpen); err != nil {
+		return nil, err
+	}
+
+	if options.Watermark, err = h.parseWatermark(query); err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
+
+// parseResponse parses the response parameter of a request to the /shrink
+// endpoint, ignoring case.
+func parseResponse(query url.Values) (Response, error) {
+	response := Response(strings.ToLower(strings.TrimSpace(query.Get("response"))))
+
+	switch response {
+	case "":
+		return DefaultResponse, nil
+	case ResponseImage, ResponseJSON:
+		return response, nil
+	default:
+		return "", &parameterError{
+			err:     fmt.Errorf("unsupported response parameter: %s", query.Get("response")),
+			message: "Unsupported response. Please choose image or json and try again.",
+		}
+	}
+}
+
+// parseWatermark builds the watermark options from the query parameters of a
+// request to the /shrink endpoint. The watermark configured for the service
+// is loaded if the watermark parameter is true; otherwise, the watermark has
+// no image until one is uploaded with the request.
+func (h *ShrinkHandler) parseWatermark(query url.Values) (*imageutil.Watermark, error) {
+	var (
+		watermark = &imageutil.Watermark{
+			Position: DefaultWatermarkPosition,
+		}
+		err error
+	)
+
+	if query.Get("wm_position") != "" {
+		watermark.Position, err = imageutil.ParseGravity(query.Get("wm_position"))
+		if err == nil && watermark.Position.Smart() {
+			err = fmt.Errorf("%w: %s", imageutil.ErrUnsupportedGravity, watermark.Position)
+		}
+
+		if err != nil {
+			return nil, &parameterError{
+				err:     fmt.Errorf("failed to parse image watermark position parameter: %w", err),
+				message: "Unsupported watermark position. Please choose centre, north, north-east, east, south-east, south, south-west, west, or north-west and try again.",
+			}
+		}
+	}
+
+	if watermark.Opacity, err = parseFloat(query, "wm_opacity", "watermark opacity", DefaultWatermarkOpacity, 0, 1); err != nil {
+		return nil, err
+	}
+
+	if watermark.Scale, err = parseFloat(query, "wm_scale", "watermark scale", DefaultWatermarkScale, 0, 1); err != nil {
+		return nil, err
+	}
+
+	if watermark.Margin, err = parseInt(query, "wm_margin", "watermark margin", DefaultWatermarkMargin, 0, int(h.cfg.Service.MaxAllowedWidth)); err != nil {
+		return nil, err
+	}
+
+	configured, err := parseBool(query, "watermark", "watermark", DefaultWatermark)
+	if err != nil {
+		return nil, err
+	}
+
+	if !configured {
+		return watermark, nil
+	}
+
+	if h.watermark == nil {
+		return nil, &parameterError{
+			err:     errors.New("image watermark parameter is set but no watermark is configured"),
+			message: "This service has no watermark configured. Please upload one in the watermark field and try again.",
+		}
+	}
+
+	watermark.Data = h.watermark
+
+	return watermark, nil
+}
@@ -0,0 +1,857 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/config"
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
+)
+
+// newTestHandler returns a ShrinkHandler limited to 1000 by 1000 pixels that
+// applies watermark when requested.
+func newTestHandler(watermark []byte) *ShrinkHandler {
+	return &ShrinkHandler{
+		cfg: &config.Config{
+			Service: &config.Service{
+				MaxAllowedWidth:  1000,
+				MaxAllowedHeight: 1000,
+			},
+		},
+		watermark: watermark,
+	}
+}
+
+func TestParseFloat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		give    string
+		want    float64
+		wantErr bool
+	}{
+		{
+			name: "missing parameter",
+			give: "",
+			want: 0.5,
+		},
+		{
+			name: "valid number",
+			give: "0.25",
+			want: 0.25,
+		},
+		{
+			name: "out of range",
+			give: "2",
+			want: 0.5,
+		},
+		{
+			name:    "not a number",
+			give:    "half",
+			wantErr: true,
+		},
+		{
+			name:    "NaN",
+			give:    "NaN",
+			wantErr: true,
+		},
+		{
+			name:    "infinity",
+			give:    "-Inf",
+			wantErr: true,
+		},
+		{
+			name:    "overflow",
+			give:    "1e999",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			query := url.Values{}
+			if tt.give != "" {
+				query.Set("opacity", tt.give)
+			}
+
+			got, err := parseFloat(query, "opacity", "opacity", 0.5, 0, 1)
+
+			var paramErr *parameterError
+			if errors.As(err, &paramErr) != tt.wantErr {
+				t.Fatalf("parseFloat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("parseFloat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDimension(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		give     string
+		dpr      float64
+		maximum  uint
+		fallback int
+		hinted   bool
+		want     int
+		wantErr  bool
+	}{
+		{
+			name:     "missing parameter",
+			give:     "",
+			dpr:      1,
+			maximum:  1000,
+			fallback: 0,
+			want:     0,
+		},
+		{
+			name:    "plain value",
+			give:    "300",
+			dpr:     1,
+			maximum: 1000,
+			want:    300,
+		},
+		{
+			name:    "scaled by device pixel ratio",
+			give:    "300",
+			dpr:     2,
+			maximum: 1000,
+			want:    600,
+		},
+		{
+			name:    "rounded after scaling",
+			give:    "101",
+			dpr:     1.5,
+			maximum: 1000,
+			want:    152,
+		},
+		{
+			name:     "negative value",
+			give:     "-10",
+			dpr:      1,
+			maximum:  1000,
+			fallback: 0,
+			want:     0,
+		},
+		{
+			name:    "equal to maximum",
+			give:    "1000",
+			dpr:     1,
+			maximum: 1000,
+			want:    1000,
+		},
+		{
+			name:    "greater than maximum",
+			give:    "1001",
+			dpr:     1,
+			maximum: 1000,
+			wantErr: true,
+		},
+		{
+			name:    "greater than maximum after scaling",
+			give:    "600",
+			dpr:     2,
+			maximum: 1000,
+			wantErr: true,
+		},
+		{
+			name:    "limited to maximum with hinted device pixel ratio",
+			give:    "600",
+			dpr:     2,
+			maximum: 1000,
+			hinted:  true,
+			want:    1000,
+		},
+		{
+			name:    "greater than maximum with hinted device pixel ratio",
+			give:    "1001",
+			dpr:     2,
+			maximum: 1000,
+			hinted:  true,
+			wantErr: true,
+		},
+		{
+			name:    "not an integer",
+			give:    "wide",
+			dpr:     1,
+			maximum: 1000,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			query := url.Values{}
+			if tt.give != "" {
+				query.Set("width", tt.give)
+			}
+
+			got, err := parseDimension(query, "width", tt.fallback, tt.dpr, tt.maximum, tt.hinted)
+
+			var paramErr *parameterError
+			if errors.As(err, &paramErr) != tt.wantErr {
+				t.Fatalf("parseDimension() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("parseDimension() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShrinkHandler_hintedWidth(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		dpr    float64
+		want   int
+	}{
+		{
+			name: "no hints",
+			dpr:  1,
+			want: 0,
+		},
+		{
+			name:   "width hint",
+			header: map[string]string{"Sec-CH-Width": "480"},
+			dpr:    2,
+			want:   480,
+		},
+		{
+			name:   "legacy width hint",
+			header: map[string]string{"Width": "480"},
+			dpr:    1,
+			want:   480,
+		},
+		{
+			name: "width hint preferred over viewport width",
+			header: map[string]string{
+				"Sec-CH-Width":          "480",
+				"Sec-CH-Viewport-Width": "320",
+			},
+			dpr:  2,
+			want: 480,
+		},
+		{
+			name:   "viewport width scaled by device pixel ratio",
+			header: map[string]string{"Sec-CH-Viewport-Width": "320"},
+			dpr:    2,
+			want:   640,
+		},
+		{
+			name:   "legacy viewport width",
+			header: map[string]string{"Viewport-Width": "320"},
+			dpr:    1.5,
+			want:   480,
+		},
+		{
+			name:   "width hint capped at maximum",
+			header: map[string]string{"Sec-CH-Width": "4000"},
+			dpr:    1,
+			want:   1000,
+		},
+		{
+			name:   "viewport width capped at maximum",
+			header: map[string]string{"Sec-CH-Viewport-Width": "800"},
+			dpr:    2,
+			want:   1000,
+		},
+		{
+			name: "invalid width hint falls back to viewport width",
+			header: map[string]string{
+				"Sec-CH-Width":          "wide",
+				"Sec-CH-Viewport-Width": "320",
+			},
+			dpr:  1,
+			want: 320,
+		},
+		{
+			name:   "zero width hint",
+			header: map[string]string{"Sec-CH-Width": "0"},
+			dpr:    1,
+			want:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			header := http.Header{}
+			for key, value := range tt.header {
+				header.Set(key, value)
+			}
+
+			if got := newTestHandler(nil).hintedWidth(header, tt.dpr); got != tt.want {
+				t.Errorf("hintedWidth() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShrinkHandler_parseOptions_ClientHints(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		query       string
+		header      map[string]string
+		wantWidth   uint
+		wantHeight  uint
+		wantQuality uint
+		wantErr     bool
+	}{
+		{
+			name:        "no hints",
+			query:       "width=300",
+			wantWidth:   300,
+			wantQuality: uint(DefaultQuality),
+		},
+		{
+			name:        "device pixel ratio hint used without dpr parameter",
+			query:       "width=300",
+			header:      map[string]string{"Sec-CH-DPR": "2"},
+			wantWidth:   600,
+			wantQuality: uint(DefaultQuality),
+		},
+		{
+			name:        "legacy device pixel ratio hint",
+			query:       "height=200",
+			header:      map[string]string{"DPR": "1.5"},
+			wantHeight:  300,
+			wantQuality: uint(DefaultQuality),
+		},
+		{
+			name:        "dpr parameter preferred over hint",
+			query:       "width=300&dpr=1",
+			header:      map[string]string{"Sec-CH-DPR": "3"},
+			wantWidth:   300,
+			wantQuality: uint(DefaultQuality),
+		},
+		{
+			name:        "device pixel ratio hint clamped to maximum",
+			query:       "width=100",
+			header:      map[string]string{"Sec-CH-DPR": "10"},
+			wantWidth:   400,
+			wantQuality: uint(DefaultQuality),
+		},
+		{
+			name:        "invalid device pixel ratio hint ignored",
+			query:       "width=300",
+			header:      map[string]string{"Sec-CH-DPR": "retina"},
+			wantWidth:   300,
+			wantQuality: uint(DefaultQuality),
+		},
+		{
+			name:        "device pixel ratio hint limited to maximum width",
+			query:       "width=600",
+			header:      map[string]string{"Sec-CH-DPR": "2"},
+			wantWidth:   1000,
+			wantQuality: uint(DefaultQuality),
+		},
+		{
+			name:    "width exceeding maximum with device pixel ratio hint",
+			query:   "width=1200",
+			header:  map[string]string{"Sec-CH-DPR": "2"},
+			wantErr: true,
+		},
+		{
+			name:        "NaN device pixel ratio hint ignored",
+			query:       "width=300",
+			header:      map[string]string{"Sec-CH-DPR": "NaN"},
+			wantWidth:   300,
+			wantQuality: uint(DefaultQuality),
+		},
+		{
+			name:        "infinite device pixel ratio hint ignored",
+			query:       "width=300",
+			header:      map[string]string{"Sec-CH-DPR": "+Inf"},
+			wantWidth:   300,
+			wantQuality: uint(DefaultQuality),
+		},
+		{
+			name:        "width hint used without dimensions",
+			header:      map[string]string{"Sec-CH-Width": "480"},
+			wantWidth:   480,
+			wantQuality: uint(DefaultQuality),
+		},
+		{
+			name: "viewport width hint scaled by device pixel ratio hint",
+			header: map[string]string{
+				"Sec-CH-Viewport-Width": "320",
+				"Sec-CH-DPR":            "2",
+			},
+			wantWidth:   640,
+			wantQuality: uint(DefaultQuality),
+		},
+		{
+			name:        "width hint ignored with height parameter",
+			query:       "height=200",
+			header:      map[string]string{"Sec-CH-Width": "480"},
+			wantHeight:  200,
+			wantQuality: uint(DefaultQuality),
+		},
+		{
+			name:        "save data lowers quality",
+			query:       "quality=60",
+			header:      map[string]string{"Save-Data": "on"},
+			wantQuality: 45,
+		},
+		{
+			name:        "save data ignores case",
+			query:       "quality=80",
+			header:      map[string]string{"Save-Data": "On"},
+			wantQuality: 60,
+		},
+		{
+			name:        "save data keeps minimum quality",
+			query:       "quality=1",
+			header:      map[string]string{"Save-Data": "on"},
+			wantQuality: 1,
+		},
+		{
+			name:        "save data off",
+			query:       "quality=60",
+			header:      map[string]string{"Save-Data": "off"},
+			wantQuality: 60,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("ParseQuery() error = %v", err)
+			}
+
+			header := http.Header{}
+			for key, value := range tt.header {
+				header.Set(key, value)
+			}
+
+			got, err := newTestHandler(nil).parseOptions(query, header)
+
+			var paramErr *parameterError
+			if errors.As(err, &paramErr) != tt.wantErr {
+				t.Fatalf("parseOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got.Width != tt.wantWidth {
+				t.Errorf("parseOptions() Width = %d, want %d", got.Width, tt.wantWidth)
+			}
+
+			if got.Height != tt.wantHeight {
+				t.Errorf("parseOptions() Height = %d, want %d", got.Height, tt.wantHeight)
+			}
+
+			if got.Quality != tt.wantQuality {
+				t.Errorf("parseOptions() Quality = %d, want %d", got.Quality, tt.wantQuality)
+			}
+		})
+	}
+}
+
+func TestShrinkHandler_parseOptions_Pad(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		give    string
+		wantErr bool
+	}{
+		{
+			name: "pixels",
+			give: "10,20,10,20",
+		},
+		{
+			name: "percentages",
+			give: "100%",
+		},
+		{
+			name: "equal to maximum",
+			give: "500",
+		},
+		{
+			name:    "wider than maximum",
+			give:    "0,600,0,600",
+			wantErr: true,
+		},
+		{
+			name:    "taller than maximum",
+			give:    "4000000,0,0,0",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			query := url.Values{}
+			query.Set("pad", tt.give)
+
+			got, err := newTestHandler(nil).parseOptions(query, http.Header{})
+
+			var paramErr *parameterError
+			if errors.As(err, &paramErr) != tt.wantErr {
+				t.Fatalf("parseOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && got.Pad == nil {
+				t.Errorf("parseOptions() Pad = nil, want padding")
+			}
+		})
+	}
+}
+
+func TestShrinkHandler_parseOptions_Subsampling(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		query   string
+		want    imageutil.Subsampling
+		wantErr bool
+	}{
+		{
+			name:  "JPEG format",
+			query: "format=jpeg&subsampling=444",
+			want:  imageutil.Subsampling444,
+		},
+		{
+			name:  "negotiated format",
+			query: "format=auto&subsampling=444",
+			want:  imageutil.Subsampling444,
+		},
+		{
+			name:  "input format",
+			query: "subsampling=420",
+			want:  imageutil.Subsampling420,
+		},
+		{
+			name:    "requested format other than JPEG",
+			query:   "format=avif&subsampling=444",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("ParseQuery() error = %v", err)
+			}
+
+			got, err := newTestHandler(nil).parseOptions(query, http.Header{})
+
+			var paramErr *parameterError
+			if errors.As(err, &paramErr) != tt.wantErr {
+				t.Fatalf("parseOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && got.Subsampling != tt.want {
+				t.Errorf("parseOptions() Subsampling = %q, want %q", got.Subsampling, tt.want)
+			}
+		})
+	}
+}
+
+func TestShrinkHandler_parseOptions_Colors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		give    string
+		want    uint
+		wantErr bool
+	}{
+		{
+			name: "default",
+			give: "",
+			want: uint(DefaultColors),
+		},
+		{
+			name: "supported palette size",
+			give: "16",
+			want: 16,
+		},
+		{
+			name:    "unsupported palette size",
+			give:    "100",
+			wantErr: true,
+		},
+		{
+			name:    "above maximum",
+			give:    "300",
+			wantErr: true,
+		},
+		{
+			name:    "below minimum",
+			give:    "1",
+			wantErr: true,
+		},
+		{
+			name:    "negative",
+			give:    "-2",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			query := url.Values{}
+			if tt.give != "" {
+				query.Set("colors", tt.give)
+			}
+
+			got, err := newTestHandler(nil).parseOptions(query, http.Header{})
+
+			var paramErr *parameterError
+			if errors.As(err, &paramErr) != tt.wantErr {
+				t.Fatalf("parseOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && got.Colors != tt.want {
+				t.Errorf("parseOptions() Colors = %d, want %d", got.Colors, tt.want)
+			}
+		})
+	}
+}
+
+func TestShrinkHandler_parseWatermark(t *testing.T) {
+	t.Parallel()
+
+	configured := []byte("watermark")
+
+	tests := []struct {
+		name      string
+		query     string
+		watermark []byte
+		want      imageutil.Watermark
+		wantErr   bool
+	}{
+		{
+			name:  "defaults",
+			query: "",
+			want: imageutil.Watermark{
+				Position: DefaultWatermarkPosition,
+				Opacity:  DefaultWatermarkOpacity,
+				Scale:    DefaultWatermarkScale,
+				Margin:   DefaultWatermarkMargin,
+			},
+		},
+		{
+			name:  "custom parameters",
+			query: "wm_position=north-west&wm_opacity=0.8&wm_scale=0.1&wm_margin=0",
+			want: imageutil.Watermark{
+				Position: imageutil.GravityNorthWest,
+				Opacity:  0.8,
+				Scale:    0.1,
+				Margin:   0,
+			},
+		},
+		{
+			name:  "out of range parameters fall back to defaults",
+			query: "wm_opacity=2&wm_scale=-1&wm_margin=5000",
+			want: imageutil.Watermark{
+				Position: DefaultWatermarkPosition,
+				Opacity:  DefaultWatermarkOpacity,
+				Scale:    DefaultWatermarkScale,
+				Margin:   DefaultWatermarkMargin,
+			},
+		},
+		{
+			name:      "configured watermark",
+			query:     "watermark=true",
+			watermark: configured,
+			want: imageutil.Watermark{
+				Data:     configured,
+				Position: DefaultWatermarkPosition,
+				Opacity:  DefaultWatermarkOpacity,
+				Scale:    DefaultWatermarkScale,
+				Margin:   DefaultWatermarkMargin,
+			},
+		},
+		{
+			name:      "configured watermark not requested",
+			query:     "watermark=false",
+			watermark: configured,
+			want: imageutil.Watermark{
+				Position: DefaultWatermarkPosition,
+				Opacity:  DefaultWatermarkOpacity,
+				Scale:    DefaultWatermarkScale,
+				Margin:   DefaultWatermarkMargin,
+			},
+		},
+		{
+			name:    "no configured watermark",
+			query:   "watermark=true",
+			wantErr: true,
+		},
+		{
+			name:    "smart position",
+			query:   "wm_position=attention",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported position",
+			query:   "wm_position=up",
+			wantErr: true,
+		},
+		{
+			name:    "invalid opacity",
+			query:   "wm_opacity=opaque",
+			wantErr: true,
+		},
+		{
+			name:    "invalid watermark parameter",
+			query:   "watermark=maybe",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("ParseQuery() error = %v", err)
+			}
+
+			got, err := newTestHandler(tt.watermark).parseWatermark(query)
+
+			var paramErr *parameterError
+			if errors.As(err, &paramErr) != tt.wantErr {
+				t.Fatalf("parseWatermark() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if !bytes.Equal(got.Data, tt.want.Data) {
+				t.Errorf("parseWatermark() Data = %q, want %q", got.Data, tt.want.Data)
+			}
+
+			if got.Position != tt.want.Position {
+				t.Errorf("parseWatermark() Position = %q, want %q", got.Position, tt.want.Position)
+			}
+
+			if got.Opacity != tt.want.Opacity {
+				t.Errorf("parseWatermark() Opacity = %v, want %v", got.Opacity, tt.want.Opacity)
+			}
+
+			if got.Scale != tt.want.Scale {
+				t.Errorf("parseWatermark() Scale = %v, want %v", got.Scale, tt.want.Scale)
+			}
+
+			if got.Margin != tt.want.Margin {
+				t.Errorf("parseWatermark() Margin = %d, want %d", got.Margin, tt.want.Margin)
+			}
+		})
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		give    string
+		want    Response
+		wantErr bool
+	}{
+		{
+			name: "missing parameter",
+			give: "",
+			want: DefaultResponse,
+		},
+		{
+			name: "image",
+			give: "image",
+			want: ResponseImage,
+		},
+		{
+			name: "json",
+			give: "json",
+			want: ResponseJSON,
+		},
+		{
+			name: "ignores case and spaces",
+			give: " JSON ",
+			want: ResponseJSON,
+		},
+		{
+			name:    "unsupported response",
+			give:    "xml",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			query := url.Values{}
+			if tt.give != "" {
+				query.Set("response", tt.give)
+			}
+
+			got, err := parseResponse(query)
+
+			var paramErr *parameterError
+			if errors.As(err, &paramErr) != tt.wantErr {
+				t.Fatalf("parseResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("parseResponse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
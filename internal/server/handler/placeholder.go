@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/config"
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/fetch"
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/serror"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
+	"go.uber.org/zap"
+)
+
+// PlaceholderResponse is the response for the /placeholder endpoint.
+type PlaceholderResponse struct {
+	// DataURI is a tiny blurred copy of the image encoded as a data URI.
+	DataURI string `json:"dataUri"`
+
+	// BlurHash is the BlurHash string of the image.
+	BlurHash string `json:"blurHash"`
+
+	// Color is the dominant color of the image in the #rrggbb format.
+	Color string `json:"color"`
+
+	// Width is the width of the image.
+	Width int `json:"width"`
+
+	// Height is the height of the image.
+	Height int `json:"height"`
+}
+
+// PlaceholderHandler is an HTTP handler for the /placeholder endpoint.
+type PlaceholderHandler struct {
+	cfg         *config.Config
+	fetchClient *fetch.Client
+	logger      *zap.Logger
+}
+
+// NewPlaceholderHandler creates a new instance of PlaceholderHandler.
+func NewPlaceholderHandler(cfg *config.Config, fetchClient *fetch.Client, logger *zap.Logger) *PlaceholderHandler {
+	return &PlaceholderHandler{
+		cfg:         cfg,
+		fetchClient: fetchClient,
+		logger:      logger,
+	}
+}
+
+// ServeHTTP serves the /placeholder endpoint.
+func (h *PlaceholderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	file, _, ok := readInput(w, r, h.cfg, h.fetchClient, h.logger)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	img, ok := openImage(w, file, h.logger)
+	if !ok {
+		return
+	}
+	defer img.Close()
+
+	placeholder, err := img.Placeholder()
+	if err != nil {
+		h.logger.Error("failed to generate placeholder", zap.Error(err))
+
+		serror.JSON(w, h.logger, serror.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Cannot process the image. Please try again.",
+		})
+
+		return
+	}
+
+	w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
+
+	err = json.NewEncoder(w).Encode(PlaceholderResponse{
+		DataURI:  placeholder.DataURI,
+		BlurHash: placeholder.BlurHash,
+		Color:    placeholder.Color.Hex(),
+		Width:    placeholder.Width,
+		Height:   placeholder.Height,
+	})
+	if err != nil {
+		h.logger.Error("failed to write response", zap.Error(err))
+
+		return
+	}
+}
@@ -2,17 +2,24 @@ package handler
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"mime/multipart"
 	"net/http"
+	"os"
+	"path"
 	"strconv"
+	"strings"
 
-	"git.sr.ht/~jamesponddotco/imgdiet-go"
 	"git.sr.ht/~jamesponddotco/shrinkimages/internal/config"
 	"git.sr.ht/~jamesponddotco/shrinkimages/internal/fetch"
+	"git.sr.ht/~jamesponddotco/shrinkimages/internal/imageutil"
 	"git.sr.ht/~jamesponddotco/shrinkimages/internal/serror"
+	"git.sr.ht/~jamesponddotco/xstd-go/xnet/xhttp"
 	"go.uber.org/zap"
 )
 
@@ -32,7 +39,12 @@ const (
 	// DefaultInterlace is the default interlace setting to use when shrinking an image.
 	DefaultInterlace bool = false
 
-	// DefaultStripMetadata is the default strip metadata setting to use when shrinking an image.
+	// DefaultSubsampling is the default chroma subsampling to use when encoding
+	// a JPEG image. Empty means libvips picks it based on the quality.
+	DefaultSubsampling imageutil.Subsampling = ""
+
+	// DefaultStripMetadata is the default strip metadata setting to use when
+	// shrinking an image. It's turned off when metadata to keep is requested.
 	DefaultStripMetadata bool = true
 
 	// DefaultOptimizeICCProfile is the default optimize ICC profile setting to use when shrinking an image.
@@ -47,361 +59,361 @@ const (
 	// DefaultOptimizeScans is the default optimize scans setting to use when shrinking an image.
 	DefaultOptimizeScans bool = true
 
-	// DefaultResize is the default resize setting to use when shrinking an image.
-	DefaultResize bool = false
-
 	// DefaultWidth is the default width to use when shrinking an image.
 	DefaultWidth int = 0
 
 	// DefaultHeight is the default height to use when shrinking an image.
 	DefaultHeight int = 0
-)
 
-// ShrinkHandler is an HTTP handler for the /shrink endpoint.
-type ShrinkHandler struct {
-	cfg         *config.Config
-	fetchClient *fetch.Client
-	logger      *zap.Logger
-}
+	// DefaultDPR is the default device pixel ratio to multiply the width and
+	// height by when shrinking an image.
+	DefaultDPR float64 = 1
 
-// NewShrinkHandler creates a new instance of ShrinkHandler.
-func NewShrinkHandler(cfg *config.Config, fetchClient *fetch.Client, logger *zap.Logger) *ShrinkHandler {
-	return &ShrinkHandler{
-		cfg:         cfg,
-		fetchClient: fetchClient,
-		logger:      logger,
-	}
-}
+	// DefaultAutoOrient is the default auto orientation setting to use when
+	// shrinking an image.
+	DefaultAutoOrient bool = true
 
-// ServeHTTP serves the /shrink endpoint.
-func (h *ShrinkHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.ContentLength > int64(h.cfg.Service.MaxUploadSize)<<20 {
-		serror.JSON(w, h.logger, serror.ErrorResponse{
-			Code:    http.StatusRequestEntityTooLarge,
-			Message: fmt.Sprintf("The image you uploaded is too large. The maximum upload size is %d. Please try again.", h.cfg.Service.MaxUploadSize),
-		})
+	// DefaultRotate is the default rotation to apply when shrinking an image.
+	DefaultRotate int = 0
 
-		return
-	}
+	// DefaultFit is the default fit mode to use when resizing an image.
+	DefaultFit imageutil.Fit = imageutil.FitCover
 
-	var (
-		quality            = DefaultQuality
-		compression        = DefaultCompression
-		quantTable         = DefaultQuantTable
-		optimizeCoding     = DefaultOptimizeCoding
-		interlace          = DefaultInterlace
-		stripMetadata      = DefaultStripMetadata
-		optimizeICCProfile = DefaultOptimizeICCProfile
-		trellisQuant       = DefaultTrellisQuant
-		overshootDeringing = DefaultOvershootDeringing
-		optimizeScans      = DefaultOptimizeScans
-		resize             = DefaultResize
-		width              = DefaultWidth
-		height             = DefaultHeight
-		err                error
-	)
-
-	if r.URL.Query().Get("quality") != "" {
-		quality, err = strconv.Atoi(r.URL.Query().Get("quality"))
-		if err != nil {
-			h.logger.Error("failed to parse image quality parameter", zap.Error(err))
+	// DefaultGravity is the default gravity to use when cropping an image.
+	DefaultGravity imageutil.Gravity = imageutil.GravityCentre
 
-			serror.JSON(w, h.logger, serror.ErrorResponse{
-				Code:    http.StatusBadRequest,
-				Message: "Cannot parse the image quality parameter. Please provide a valid integer and try again.",
-			})
+	// DefaultBackground is the default background color to use when
+	// letterboxing or padding an image, or flattening a transparent one.
+	DefaultBackground string = "#ffffff"
 
-			return
-		}
+	// DefaultMaxBytes is the default maximum size of a shrunk image in bytes.
+	// Zero means there is no limit.
+	DefaultMaxBytes int = 0
 
-		if quality < 1 || quality > 100 {
-			quality = DefaultQuality
-		}
-	}
+	// DefaultTargetSSIM is the default structural similarity to target when
+	// shrinking an image. Zero means the quality parameter is used instead.
+	DefaultTargetSSIM float64 = 0
 
-	if r.URL.Query().Get("compression") != "" {
-		compression, err = strconv.Atoi(r.URL.Query().Get("compression"))
-		if err != nil {
-			h.logger.Error("failed to parse image compression parameter", zap.Error(err))
+	// DefaultEffort is the default CPU effort to use when encoding a WebP or
+	// AVIF image.
+	DefaultEffort int = 4
 
-			serror.JSON(w, h.logger, serror.ErrorResponse{
-				Code:    http.StatusBadRequest,
-				Message: "Cannot parse the image compression parameter. Please provide a valid integer and try again.",
-			})
+	// DefaultLossless is the default lossless setting to use when encoding a
+	// WebP or AVIF image.
+	DefaultLossless bool = false
 
-			return
-		}
+	// DefaultNearLossless is the default near lossless setting to use when
+	// encoding a WebP image.
+	DefaultNearLossless bool = false
 
-		if compression < 0 || compression > 9 {
-			compression = DefaultCompression
-		}
-	}
+	// DefaultAlphaQuality is the default quality of the alpha channel of a
+	// lossy WebP image. 100 keeps the alpha channel unchanged.
+	DefaultAlphaQuality int = 100
 
-	if r.URL.Query().Get("quant_table") != "" {
-		quantTable, err = strconv.Atoi(r.URL.Query().Get("quant_table"))
-		if err != nil {
-			h.logger.Error("failed to parse image quantization table parameter", zap.Error(err))
+	// DefaultColorspace is the default color space to convert an image to.
+	// Empty means the image is left in its own color space.
+	DefaultColorspace imageutil.Colorspace = ""
 
-			serror.JSON(w, h.logger, serror.ErrorResponse{
-				Code:    http.StatusBadRequest,
-				Message: "Cannot parse the image quantization table parameter. Please provide a valid integer and try again.",
-			})
+	// DefaultTrim is the default threshold to use when trimming the borders
+	// of an image. Zero means the image is not trimmed.
+	DefaultTrim float64 = 0
 
-			return
-		}
+	// DefaultGrayscale is the default grayscale setting to use when shrinking
+	// an image.
+	DefaultGrayscale bool = false
 
-		if quantTable < 0 || quantTable > 8 {
-			quantTable = DefaultQuantTable
-		}
-	}
+	// DefaultBrightness is the default brightness adjustment to apply when
+	// shrinking an image.
+	DefaultBrightness float64 = 0
 
-	if r.URL.Query().Get("optimize_coding") != "" {
-		optimizeCoding, err = strconv.ParseBool(r.URL.Query().Get("optimize_coding"))
-		if err != nil {
-			h.logger.Error("failed to parse image optimize coding parameter", zap.Error(err))
+	// DefaultContrast is the default contrast adjustment to apply when
+	// shrinking an image.
+	DefaultContrast float64 = 0
 
-			serror.JSON(w, h.logger, serror.ErrorResponse{
-				Code:    http.StatusBadRequest,
-				Message: "Cannot parse the image optimize coding parameter. Please provide a valid boolean and try again.",
-			})
+	// DefaultGamma is the default gamma correction to apply when shrinking an
+	// image.
+	DefaultGamma float64 = 1
 
-			return
-		}
-	}
+	// DefaultBlur is the default Gaussian blur sigma to apply when shrinking
+	// an image.
+	DefaultBlur float64 = 0
 
-	if r.URL.Query().Get("interlace") != "" {
-		interlace, err = strconv.ParseBool(r.URL.Query().Get("interlace"))
-		if err != nil {
-			h.logger.Error("failed to parse image interlace parameter", zap.Error(err))
+	// DefaultSharpen is the default sharpening sigma to apply when shrinking
+	// an image.
+	DefaultSharpen float64 = 0
 
-			serror.JSON(w, h.logger, serror.ErrorResponse{
-				Code:    http.StatusBadRequest,
-				Message: "Cannot parse the image interlace parameter. Please provide a valid boolean and try again.",
-			})
+	// DefaultWatermark is the default setting for applying the watermark
+	// configured for the service.
+	DefaultWatermark bool = false
 
-			return
-		}
-	}
+	// DefaultWatermarkPosition is the default position of the watermark.
+	DefaultWatermarkPosition imageutil.Gravity = imageutil.GravitySouthEast
 
-	if r.URL.Query().Get("strip") != "" {
-		stripMetadata, err = strconv.ParseBool(r.URL.Query().Get("strip_metadata"))
-		if err != nil {
-			h.logger.Error("failed to parse image strip metadata parameter", zap.Error(err))
+	// DefaultWatermarkOpacity is the default opacity of the watermark.
+	DefaultWatermarkOpacity float64 = 0.5
 
-			serror.JSON(w, h.logger, serror.ErrorResponse{
-				Code:    http.StatusBadRequest,
-				Message: "Cannot parse the image strip metadata parameter. Please provide a valid boolean and try again.",
-			})
+	// DefaultWatermarkScale is the default width of the watermark relative to
+	// the width of the image.
+	DefaultWatermarkScale float64 = 0.25
 
-			return
-		}
-	}
+	// DefaultWatermarkMargin is the default distance between the watermark
+	// and the edges of the image, in pixels.
+	DefaultWatermarkMargin int = 16
 
-	if r.URL.Query().Get("optimize_icc_profile") != "" {
-		optimizeICCProfile, err = strconv.ParseBool(r.URL.Query().Get("optimize_icc_profile"))
-		if err != nil {
-			h.logger.Error("failed to parse image optimize ICC profile parameter", zap.Error(err))
+	// DefaultPalette is the default palette quantization setting to use when
+	// encoding a PNG image.
+	DefaultPalette bool = false
 
-			serror.JSON(w, h.logger, serror.ErrorResponse{
-				Code:    http.StatusBadRequest,
-				Message: "Cannot parse the image optimize ICC profile parameter. Please provide a valid boolean and try again.",
-			})
+	// DefaultColors is the default maximum number of colors in the palette of
+	// a quantized PNG image.
+	DefaultColors int = 256
 
-			return
-		}
-	}
+	// DefaultDither is the default amount of dithering to use when quantizing
+	// a PNG image.
+	DefaultDither float64 = 1
 
-	if r.URL.Query().Get("trellis_quant") != "" {
-		trellisQuant, err = strconv.ParseBool(r.URL.Query().Get("trellis_quant"))
-		if err != nil {
-			h.logger.Error("failed to parse image trellis quant parameter", zap.Error(err))
+	// DefaultResponse is the default kind of response returned by the /shrink
+	// endpoint.
+	DefaultResponse Response = ResponseImage
+)
 
-			serror.JSON(w, h.logger, serror.ErrorResponse{
-				Code:    http.StatusBadRequest,
-				Message: "Cannot parse the image trellis quant parameter. Please provide a valid boolean and try again.",
-			})
+// Response represents the kind of response returned by the /shrink endpoint.
+type Response string
 
-			return
-		}
-	}
+// List of kinds of response supported by the /shrink endpoint.
+const (
+	// ResponseImage returns the shrunk image itself.
+	ResponseImage Response = "image"
 
-	if r.URL.Query().Get("overshoot_deringing") != "" {
-		overshootDeringing, err = strconv.ParseBool(r.URL.Query().Get("overshoot_deringing"))
-		if err != nil {
-			h.logger.Error("failed to parse image overshoot deringing parameter", zap.Error(err))
+	// ResponseJSON returns the shrunk image encoded as base64 in a JSON
+	// document, together with statistics about the optimization.
+	ResponseJSON Response = "json"
+)
 
-			serror.JSON(w, h.logger, serror.ErrorResponse{
-				Code:    http.StatusBadRequest,
-				Message: "Cannot parse the image overshoot deringing parameter. Please provide a valid boolean and try again.",
-			})
+// ShrinkResponse is the response for the /shrink endpoint when a JSON
+// response is requested.
+type ShrinkResponse struct {
+	// Format is the format of the shrunk image.
+	Format string `json:"format"`
 
-			return
-		}
-	}
+	// Filename is the file name of the shrunk image.
+	Filename string `json:"filename"`
 
-	if r.URL.Query().Get("optimize_scans") != "" {
-		optimizeScans, err = strconv.ParseBool(r.URL.Query().Get("optimize_scans"))
-		if err != nil {
-			h.logger.Error("failed to parse image optimize scans parameter", zap.Error(err))
+	// Data is the shrunk image encoded as base64.
+	Data string `json:"data"`
 
-			serror.JSON(w, h.logger, serror.ErrorResponse{
-				Code:    http.StatusBadRequest,
-				Message: "Cannot parse the image optimize scans parameter. Please provide a valid boolean and try again.",
-			})
+	// OriginalSize is the size of the input image in bytes.
+	OriginalSize int `json:"originalSize"`
 
-			return
-		}
-	}
+	// Size is the size of the shrunk image in bytes.
+	Size int `json:"size"`
 
-	if r.URL.Query().Get("width") != "" {
-		width, err = strconv.Atoi(r.URL.Query().Get("width"))
-		if err != nil {
-			h.logger.Error("failed to parse image width parameter", zap.Error(err))
+	// Savings is the reduction in size, as a percentage of the original size.
+	// It's negative if the shrunk image is larger than the input image.
+	Savings float64 `json:"savings"`
 
-			serror.JSON(w, h.logger, serror.ErrorResponse{
-				Code:    http.StatusBadRequest,
-				Message: "Cannot parse the image width parameter. Please provide a valid integer and try again.",
-			})
+	// Width is the width of the shrunk image.
+	Width int `json:"width"`
 
-			return
-		}
+	// Height is the height of the shrunk image, or of a single frame for
+	// animated images.
+	Height int `json:"height"`
 
-		if width > int(h.cfg.Service.MaxAllowedWidth) {
-			serror.JSON(w, h.logger, serror.ErrorResponse{
-				Code:    http.StatusBadRequest,
-				Message: fmt.Sprintf("The image width parameter cannot be greater than %d. Please provide a valid integer and try again.", h.cfg.Service.MaxAllowedWidth),
-			})
+	// Quality is the quality the image was encoded with.
+	Quality uint `json:"quality,omitempty"`
 
-			return
-		}
+	// SSIM is the structural similarity between the unencoded and shrunk
+	// image, when a target SSIM is requested.
+	SSIM float64 `json:"ssim,omitempty"`
+}
 
-		if width < 0 {
-			width = DefaultWidth
-		}
+const (
+	// MinDPR is the minimum device pixel ratio accepted by the /shrink
+	// endpoint.
+	MinDPR float64 = 1
+
+	// MaxDPR is the maximum device pixel ratio accepted by the /shrink
+	// endpoint.
+	MaxDPR float64 = 4
+
+	// MaxTrim is the maximum trim threshold accepted by the /shrink endpoint.
+	MaxTrim float64 = 255
+
+	// MaxBlur is the maximum Gaussian blur sigma accepted by the /shrink
+	// endpoint.
+	MaxBlur float64 = 100
+
+	// MaxSharpen is the maximum sharpening sigma accepted by the /shrink
+	// endpoint.
+	MaxSharpen float64 = 10
+
+	// MinGamma is the minimum gamma correction accepted by the /shrink
+	// endpoint.
+	MinGamma float64 = 0.1
+
+	// MaxGamma is the maximum gamma correction accepted by the /shrink
+	// endpoint.
+	MaxGamma float64 = 10
+
+	// SaveDataQualityFactor is the factor the quality is multiplied by when
+	// the client sends the Save-Data client hint.
+	SaveDataQualityFactor float64 = 0.75
+)
+
+// ShrinkHandler is an HTTP handler for the /shrink endpoint.
+type ShrinkHandler struct {
+	cfg         *config.Config
+	fetchClient *fetch.Client
+	logger      *zap.Logger
+
+	// watermark is the watermark image configured for the service, if any.
+	watermark []byte
+}
+
+// NewShrinkHandler creates a new instance of ShrinkHandler. The watermark
+// configured for the service is read and checked once, so an invalid one is
+// reported at startup rather than on every request.
+func NewShrinkHandler(cfg *config.Config, fetchClient *fetch.Client, logger *zap.Logger) (*ShrinkHandler, error) {
+	h := &ShrinkHandler{
+		cfg:         cfg,
+		fetchClient: fetchClient,
+		logger:      logger,
 	}
 
-	if r.URL.Query().Get("height") != "" {
-		height, err = strconv.Atoi(r.URL.Query().Get("height"))
-		if err != nil {
-			h.logger.Error("failed to parse image height parameter", zap.Error(err))
+	if cfg.Service.Watermark == "" {
+		return h, nil
+	}
 
-			serror.JSON(w, h.logger, serror.ErrorResponse{
-				Code:    http.StatusBadRequest,
-				Message: "Cannot parse the image height parameter. Please provide a valid integer and try again.",
-			})
+	watermark, err := os.ReadFile(cfg.Service.Watermark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watermark: %w", err)
+	}
 
-			return
-		}
+	if err = imageutil.ValidateWatermark(watermark); err != nil {
+		return nil, fmt.Errorf("failed to decode watermark %s: %w", cfg.Service.Watermark, err)
+	}
 
-		if height > int(h.cfg.Service.MaxAllowedHeight) {
-			serror.JSON(w, h.logger, serror.ErrorResponse{
-				Code:    http.StatusBadRequest,
-				Message: fmt.Sprintf("The image height parameter cannot be greater than %d. Please provide a valid integer and try again.", h.cfg.Service.MaxAllowedHeight),
-			})
+	h.watermark = watermark
 
-			return
-		}
+	return h, nil
+}
 
-		if height < 0 {
-			height = DefaultHeight
-		}
+// ServeHTTP serves the /shrink endpoint.
+func (h *ShrinkHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	options, err := h.parseOptions(r.URL.Query(), r.Header)
+	if err != nil {
+		h.parameterFailure(w, err)
+
+		return
 	}
 
-	if width > 0 || height > 0 {
-		resize = true
+	response, err := parseResponse(r.URL.Query())
+	if err != nil {
+		h.parameterFailure(w, err)
+
+		return
 	}
 
-	options := &imgdiet.Options{
-		Quality:            uint(quality),
-		Compression:        uint(compression),
-		QuantTable:         uint(quantTable),
-		OptimizeCoding:     optimizeCoding,
-		Interlaced:         interlace,
-		StripMetadata:      stripMetadata,
-		OptimizeICCProfile: optimizeICCProfile,
-		TrellisQuant:       trellisQuant,
-		OvershootDeringing: overshootDeringing,
-		OptimizeScans:      optimizeScans,
+	file, filename, ok := readInput(w, r, h.cfg, h.fetchClient, h.logger)
+	if !ok {
+		return
 	}
+	defer file.Close()
 
-	var (
-		maxUploadSize = h.cfg.Service.MaxUploadSize * 1024 * 1024
-		uri           = r.URL.Query().Get("url")
-		file          io.ReadCloser
-		header        *multipart.FileHeader
-		data          []byte
-		filename      string
-	)
+	if r.MultipartForm != nil {
+		var watermark multipart.File
 
-	if uri != "" {
-		data, filename, err = h.fetchClient.Remote(r.Context(), uri)
-		if err != nil {
-			h.logger.Error("failed to fetch remote image", zap.Error(err))
+		watermark, _, err = r.FormFile("watermark")
+		if err != nil && !errors.Is(err, http.ErrMissingFile) {
+			h.logger.Error("failed to get watermark file", zap.Error(err))
 
 			serror.JSON(w, h.logger, serror.ErrorResponse{
 				Code:    http.StatusBadRequest,
-				Message: "Cannot fetch the remote image. Please provide a valid image and try again.",
+				Message: "Cannot process the watermark. Please provide a valid image and try again.",
 			})
 
 			return
 		}
 
-		if len(data) > int(maxUploadSize) {
-			h.logger.Error("image size is too large", zap.Error(err))
+		if err == nil {
+			defer watermark.Close()
+
+			options.Watermark.Data, err = io.ReadAll(watermark)
+			if err != nil {
+				h.logger.Error("failed to read watermark file", zap.Error(err))
+
+				serror.JSON(w, h.logger, serror.ErrorResponse{
+					Code:    http.StatusBadRequest,
+					Message: "Cannot process the watermark. Please provide a valid image and try again.",
+				})
+
+				return
+			}
+		}
+	}
+
+	img, ok := openImage(w, file, h.logger)
+	if !ok {
+		return
+	}
+	defer img.Close()
+
+	switch options.Format {
+	case "":
+		options.Format = imageutil.DefaultOutputFormat(img.Format())
+	case imageutil.FormatAuto:
+		options.Format = imageutil.NegotiateFormat(r.Header.Get("Accept"), imageutil.DefaultOutputFormat(img.Format()), img.Animated())
+
+		w.Header().Add("Vary", "Accept")
+	}
+
+	result, err := img.Process(options)
+	if err != nil {
+		if errors.Is(err, imageutil.ErrInvalidRegion) {
+			h.logger.Error("invalid crop region", zap.Error(err))
 
 			serror.JSON(w, h.logger, serror.ErrorResponse{
 				Code:    http.StatusBadRequest,
-				Message: fmt.Sprintf("The image size cannot be greater than %d MB. Please provide a valid image and try again.", h.cfg.Service.MaxUploadSize),
+				Message: "The image crop parameter is outside the image. Please provide a region within the image bounds and try again.",
 			})
 
 			return
 		}
 
-		file = io.NopCloser(bytes.NewReader(data))
-		defer file.Close()
-	} else {
-		r.Body = http.MaxBytesReader(w, r.Body, int64(maxUploadSize))
-		if err = r.ParseMultipartForm(int64(maxUploadSize)); err != nil {
-			h.logger.Error("failed to parse multipart form", zap.Error(err))
+		if errors.Is(err, imageutil.ErrInvalidWatermark) {
+			h.logger.Error("invalid watermark", zap.Error(err))
 
 			serror.JSON(w, h.logger, serror.ErrorResponse{
 				Code:    http.StatusBadRequest,
-				Message: "Cannot process the image. Please provide a valid image and try again.",
+				Message: "Cannot process the watermark. Please provide a valid image and try again.",
 			})
 
 			return
 		}
 
-		file, header, err = r.FormFile("input")
-		if err != nil {
-			h.logger.Error("failed to get input file", zap.Error(err))
+		if errors.Is(err, imageutil.ErrUnsupportedConversion) {
+			h.logger.Error("unsupported conversion", zap.Error(err))
 
 			serror.JSON(w, h.logger, serror.ErrorResponse{
 				Code:    http.StatusBadRequest,
-				Message: "Cannot process the image. Please provide a valid image and try again.",
+				Message: "Cannot convert a raster image to SVG. Please choose a different output format and try again.",
 			})
 
 			return
 		}
-		defer file.Close()
 
-		filename = header.Filename
-	}
-
-	img, err := imgdiet.Open(file)
-	if err != nil {
-		if errors.Is(err, imgdiet.ErrUnsupportedImageFormat) {
-			h.logger.Error("unsupported image format", zap.Error(err))
+		if errors.Is(err, imageutil.ErrMaxBytesExceeded) {
+			h.logger.Error("image cannot be encoded within the maximum size", zap.Error(err))
 
 			serror.JSON(w, h.logger, serror.ErrorResponse{
-				Code:    http.StatusUnsupportedMediaType,
-				Message: "Unsupported image format. Please provide a valid JPEG or PNG image and try again.",
+				Code:    http.StatusUnprocessableEntity,
+				Message: fmt.Sprintf("Cannot shrink the image to %d bytes or less. Please increase the max bytes parameter or reduce the image dimensions and try again.", options.MaxBytes),
 			})
 
 			return
 		}
 
-		h.logger.Error("failed to open image", zap.Error(err))
+		h.logger.Error("failed to process image", zap.Error(err))
 
 		serror.JSON(w, h.logger, serror.ErrorResponse{
 			Code:    http.StatusInternalServerError,
@@ -410,40 +422,27 @@ func (h *ShrinkHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 		return
 	}
-	defer img.Close()
 
-	var optimizedImage []byte
+	filename = strings.TrimSuffix(filename, path.Ext(filename)) + result.Format.Extension()
 
-	if resize {
-		optimizedImage, err = img.Resize(uint(width), uint(height), options)
-		if err != nil {
-			h.logger.Error("failed to resize image", zap.Error(err))
+	if response == ResponseJSON {
+		h.writeJSON(w, img, result, filename)
 
-			serror.JSON(w, h.logger, serror.ErrorResponse{
-				Code:    http.StatusInternalServerError,
-				Message: "Cannot process the image. Please try again.",
-			})
+		return
+	}
 
-			return
-		}
-	} else {
-		optimizedImage, err = img.Optimize(options)
-		if err != nil {
-			h.logger.Error("failed to optimize image", zap.Error(err))
+	w.Header().Set("Content-Type", result.Format.MIMEType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
 
-			serror.JSON(w, h.logger, serror.ErrorResponse{
-				Code:    http.StatusInternalServerError,
-				Message: "Cannot process the image. Please try again.",
-			})
+	if !result.Format.Vector() {
+		w.Header().Set("Image-Quality", strconv.FormatUint(uint64(result.Quality), 10))
 
-			return
+		if options.TargetSSIM > 0 {
+			w.Header().Set("Image-SSIM", strconv.FormatFloat(result.SSIM, 'f', 4, 64))
 		}
 	}
 
-	w.Header().Set("Content-Type", "octet/stream")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-
-	_, err = io.Copy(w, bytes.NewReader(optimizedImage))
+	_, err = io.Copy(w, bytes.NewReader(result.Data))
 	if err != nil {
 		h.logger.Error("failed to write optimized image", zap.Error(err))
 
@@ -455,3 +454,57 @@ func (h *ShrinkHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// parameterFailure writes the error response for a request parameter that
+// cannot be parsed.
+func (h *ShrinkHandler) parameterFailure(w http.ResponseWriter, err error) {
+	h.logger.Error("failed to parse request parameters", zap.Error(err))
+
+	var paramErr *parameterError
+	if !errors.As(err, &paramErr) {
+		serror.JSON(w, h.logger, serror.ErrorResponse{
+			Code:    http.StatusInternalServerError,
+			Message: "Cannot process the image. Please try again.",
+		})
+
+		return
+	}
+
+	serror.JSON(w, h.logger, serror.ErrorResponse{
+		Code:    http.StatusBadRequest,
+		Message: paramErr.message,
+	})
+}
+
+// writeJSON writes the shrunk image and statistics about the optimization as
+// a JSON document.
+func (h *ShrinkHandler) writeJSON(w http.ResponseWriter, img *imageutil.Image, result *imageutil.Result, filename string) {
+	var savings float64
+	if img.Size() > 0 {
+		savings = math.Round((1-float64(len(result.Data))/float64(img.Size()))*10000) / 100
+	}
+
+	response := ShrinkResponse{
+		Format:       string(result.Format),
+		Filename:     filename,
+		Data:         base64.StdEncoding.EncodeToString(result.Data),
+		OriginalSize: img.Size(),
+		Size:         len(result.Data),
+		Savings:      savings,
+		Width:        result.Width,
+		Height:       result.Height,
+		SSIM:         result.SSIM,
+	}
+
+	if !result.Format.Vector() {
+		response.Quality = result.Quality
+	}
+
+	w.Header().Set(xhttp.ContentType, xhttp.ApplicationJSON)
+
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("failed to write response", zap.Error(err))
+
+		return
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// clientHints is the list of client hints the service uses when resizing
+// images, including their legacy names.
+var clientHints = []string{
+	"Sec-CH-DPR",
+	"Sec-CH-Width",
+	"Sec-CH-Viewport-Width",
+	"DPR",
+	"Width",
+	"Viewport-Width",
+	"Save-Data",
+}
+
+// ClientHints asks browsers to send the device pixel ratio, width, and
+// viewport width client hints, and marks the response as varying on them.
+func ClientHints(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-CH", "Sec-CH-DPR, Sec-CH-Width, Sec-CH-Viewport-Width")
+		w.Header().Add("Vary", strings.Join(clientHints, ", "))
+
+		next.ServeHTTP(w, r)
+	})
+}
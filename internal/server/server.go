@@ -67,11 +67,17 @@ func New(cfg *config.Config, logger *zap.Logger) (*Server, error) {
 	}
 
 	var (
-		fetchInstance = fetch.New(cfg.Service.Name, cfg.Service.Contact)
-		pingHandler   = handler.NewPingHandler(logger)
-		shrinkHandler = handler.NewShrinkHandler(cfg, fetchInstance, logger)
+		fetchInstance      = fetch.New(cfg.Service.Name, cfg.Service.Contact)
+		pingHandler        = handler.NewPingHandler(logger)
+		placeholderHandler = handler.NewPlaceholderHandler(cfg, fetchInstance, logger)
+		infoHandler        = handler.NewInfoHandler(cfg, fetchInstance, logger)
 	)
 
+	shrinkHandler, err := handler.NewShrinkHandler(cfg, fetchInstance, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create shrink handler: %w", err)
+	}
+
 	mux := http.NewServeMux()
 	mux.HandleFunc(endpoint.Root, func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
@@ -86,7 +92,9 @@ func New(cfg *config.Config, logger *zap.Logger) (*Server, error) {
 	})
 
 	mux.Handle(endpoint.Ping, middleware.Chain(pingHandler, middlewares...))
-	mux.Handle(endpoint.Shrink, middleware.Chain(shrinkHandler, middlewares...))
+	mux.Handle(endpoint.Shrink, middleware.Chain(middleware.ClientHints(shrinkHandler), middlewares...))
+	mux.Handle(endpoint.Placeholder, middleware.Chain(placeholderHandler, middlewares...))
+	mux.Handle(endpoint.Info, middleware.Chain(infoHandler, middlewares...))
 
 	httpServer := &http.Server{
 		Addr:         cfg.Server.Address,